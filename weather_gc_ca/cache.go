@@ -0,0 +1,276 @@
+package weather_gc_ca
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCacheMaxAge is how long a dataset for an incomplete period is kept by a FileCache
+const DefaultCacheMaxAge = 24 * time.Hour
+
+// Cache stores the raw XML of retreived datasets, it is used by a Client to avoid
+// requesting the same dataset again
+type Cache interface {
+	Get(key CacheKey) ([]byte, bool)
+	Put(key CacheKey, b []byte) error
+}
+
+// CacheKey identifies a single dataset request, Month is 1 for the intervals
+// that are retreived a year at a time
+type CacheKey struct {
+	StationID int
+	Interval  Interval
+	Year      int
+	Month     int
+}
+
+func (k CacheKey) String() string {
+	return fmt.Sprintf("%d/%s/%d-%02d", k.StationID, k.Interval, k.Year, k.Month)
+}
+
+// end returns the time the period of the dataset ends, the zero time if the dataset
+// never completes (monthly and almanac are the whole period of record of the station)
+func (k CacheKey) end() time.Time {
+	switch k.Interval {
+	case Hourly:
+		return time.Date(k.Year, time.Month(k.Month)+1, 1, 0, 0, 0, 0, time.UTC)
+	case Daily:
+		return time.Date(k.Year+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Time{}
+}
+
+// Expired reports whether a dataset cached at the given time should be retreived again.
+// A dataset cached after its period ended never expires, a dataset for the current period
+// (eg. the current month) expires once it is older than maxAge
+func (k CacheKey) Expired(cached, now time.Time, maxAge time.Duration) bool {
+	end := k.end()
+	if !end.IsZero() && !cached.Before(end) {
+		return false
+	}
+	return now.Sub(cached) > maxAge
+}
+
+// FileCache is a Cache storing each dataset as a file in Dir:
+//
+//	{Dir}/{StationID}/{interval}/{year}-{month}.xml
+type FileCache struct {
+	Dir    string
+	MaxAge time.Duration // max age of a dataset for an incomplete period
+
+	now func() time.Time
+}
+
+// NewFileCache returns a FileCache in dir using DefaultCacheMaxAge
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{
+		Dir:    dir,
+		MaxAge: DefaultCacheMaxAge,
+		now:    time.Now,
+	}
+}
+
+// CacheEntry describes a dataset stored in a FileCache
+type CacheEntry struct {
+	Key     CacheKey
+	Path    string
+	Size    int64
+	Cached  time.Time
+	Expired bool
+}
+
+func (f *FileCache) path(key CacheKey) string {
+	return filepath.Join(f.Dir,
+		strconv.Itoa(key.StationID),
+		strings.ToLower(key.Interval.String()),
+		fmt.Sprintf("%d-%02d.xml", key.Year, key.Month))
+}
+
+func (f *FileCache) time() time.Time {
+	if f.now == nil {
+		return time.Now()
+	}
+	return f.now()
+}
+
+func (f *FileCache) Get(key CacheKey) ([]byte, bool) {
+	p := f.path(key)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, false
+	}
+
+	if key.Expired(info.ModTime(), f.time(), f.MaxAge) {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// Put writes the dataset to a temporary file and renames it into place so a
+// partially written dataset is never read
+func (f *FileCache) Put(key CacheKey, b []byte) error {
+	p := f.path(key)
+	err := os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %s", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %s", err)
+	}
+
+	err = os.Rename(tmp.Name(), p)
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %s", err)
+	}
+
+	return nil
+}
+
+// List returns every dataset in the cache sorted by key
+func (f *FileCache) List() ([]CacheEntry, error) {
+	entries := []CacheEntry{}
+	now := f.time()
+	err := filepath.WalkDir(f.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == f.Dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		key, ok := f.parse(p)
+		if !ok {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, CacheEntry{
+			Key:     key,
+			Path:    p,
+			Size:    info.Size(),
+			Cached:  info.ModTime(),
+			Expired: key.Expired(info.ModTime(), now, f.MaxAge),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache: %s", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].Key, entries[j].Key
+		if a.StationID != b.StationID {
+			return a.StationID < b.StationID
+		}
+		if a.Interval != b.Interval {
+			return a.Interval < b.Interval
+		}
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		return a.Month < b.Month
+	})
+
+	return entries, nil
+}
+
+// parse returns the key of a cache file path
+func (f *FileCache) parse(p string) (key CacheKey, ok bool) {
+	rel, err := filepath.Rel(f.Dir, p)
+	if err != nil {
+		return key, false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".xml") {
+		return key, false
+	}
+
+	key.StationID, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return key, false
+	}
+
+	key.Interval = IntervalString(parts[1])
+	if key.Interval == 0 {
+		return key, false
+	}
+
+	_, err = fmt.Sscanf(strings.TrimSuffix(parts[2], ".xml"), "%d-%d", &key.Year, &key.Month)
+	if err != nil {
+		return key, false
+	}
+
+	return key, true
+}
+
+// Prune removes the expired datasets, returning the number removed
+func (f *FileCache) Prune() (int, error) {
+	return f.remove(func(e CacheEntry) bool {
+		return e.Expired
+	})
+}
+
+// Clear removes every dataset from the cache, returning the number removed.
+// Only files named by the cache layout are removed from Dir
+func (f *FileCache) Clear() (int, error) {
+	return f.remove(func(e CacheEntry) bool {
+		return true
+	})
+}
+
+func (f *FileCache) remove(match func(CacheEntry) bool) (int, error) {
+	entries, err := f.List()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, e := range entries {
+		if !match(e) {
+			continue
+		}
+		err = os.Remove(e.Path)
+		if err != nil {
+			return n, fmt.Errorf("failed to remove %s from cache: %s", e.Key, err)
+		}
+		n++
+
+		// remove the interval and station directories once empty
+		dir := filepath.Dir(e.Path)
+		if os.Remove(dir) == nil {
+			os.Remove(filepath.Dir(dir))
+		}
+	}
+
+	return n, nil
+}
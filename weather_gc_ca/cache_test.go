@@ -0,0 +1,118 @@
+package weather_gc_ca
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheKeyExpired(t *testing.T) {
+	now := time.Date(2021, 6, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		key     CacheKey
+		cached  time.Time
+		expired bool
+	}{
+		{"past year never expires", CacheKey{Interval: Daily, Year: 2019, Month: 1}, time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC), false},
+		{"year cached before it ended expires", CacheKey{Interval: Daily, Year: 2019, Month: 1}, time.Date(2019, 7, 5, 0, 0, 0, 0, time.UTC), true},
+		{"current year expires", CacheKey{Interval: Daily, Year: 2021, Month: 1}, now.Add(-2 * day), true},
+		{"current year is fresh", CacheKey{Interval: Daily, Year: 2021, Month: 1}, now.Add(-time.Hour), false},
+		{"past month never expires", CacheKey{Interval: Hourly, Year: 2021, Month: 5}, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), false},
+		{"month cached before it ended expires", CacheKey{Interval: Hourly, Year: 2021, Month: 5}, time.Date(2021, 5, 20, 0, 0, 0, 0, time.UTC), true},
+		{"current month expires", CacheKey{Interval: Hourly, Year: 2021, Month: 6}, now.Add(-2 * day), true},
+		{"almanac expires", CacheKey{Interval: Almanac, Year: 1937, Month: 1}, now.Add(-2 * day), true},
+		{"monthly of a past year expires", CacheKey{Interval: Monthly, Year: 2019, Month: 1}, time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC), true},
+		{"monthly is fresh", CacheKey{Interval: Monthly, Year: 2019, Month: 1}, now.Add(-time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expired, tt.key.Expired(tt.cached, now, day))
+		})
+	}
+}
+
+func TestFileCache(t *testing.T) {
+	dir := t.TempDir()
+	c := NewFileCache(dir)
+	past := CacheKey{StationID: 51459, Interval: Daily, Year: 1992, Month: 1}
+	current := CacheKey{StationID: 51459, Interval: Hourly, Year: time.Now().Year(), Month: int(time.Now().Month())}
+
+	_, ok := c.Get(past)
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Put(past, []byte("past")))
+	assert.NoError(t, c.Put(current, []byte("current")))
+	assert.FileExists(t, filepath.Join(dir, "51459", "daily", "1992-01.xml"))
+
+	b, ok := c.Get(past)
+	assert.True(t, ok)
+	assert.Equal(t, "past", string(b))
+
+	// a file outside of the cache layout is never listed or removed
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0644))
+
+	entries, err := c.List()
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(entries)) {
+		assert.Equal(t, current, entries[0].Key)
+		assert.Equal(t, past, entries[1].Key)
+		assert.False(t, entries[0].Expired)
+	}
+
+	// the current month expires once the max age has passed
+	c.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	_, ok = c.Get(current)
+	assert.False(t, ok)
+	_, ok = c.Get(past)
+	assert.True(t, ok)
+
+	n, err := c.Prune()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoDirExists(t, filepath.Join(dir, "51459", "hourly"))
+
+	n, err = c.Clear()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoDirExists(t, filepath.Join(dir, "51459"))
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+
+	entries, err = NewFileCache(filepath.Join(dir, "missing")).List()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestClientCache(t *testing.T) {
+	s, requests := newTestServer(t)
+	c := NewClient().HTTPClient(s.Client()).BaseURL(s.URL).Cache(NewFileCache(t.TempDir()))
+
+	download := func() int {
+		st := StationMetadata{StationID: 51459, DailyFirstYear: 1992, DailyLastYear: 1993}
+		status := st.WithClient(c).RetreiveDailyData(context.Background())
+		for {
+			select {
+			case p := <-status.Progress:
+				assert.NoError(t, p.Error)
+			case <-status.Done:
+				return len(*st.XML.Data.(*DailyDataXML))
+			}
+		}
+	}
+
+	// a request for each year
+	assert.Equal(t, 366, download())
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+
+	// the second download is served from the cache
+	assert.Equal(t, 366, download())
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+}
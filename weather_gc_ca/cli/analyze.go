@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	climatedata "github.com/cleanflo/open_data/weather_gc_ca"
+	"github.com/urfave/cli/v2"
+)
+
+// dateLayout is the layout of the dates of the analyze commands
+const dateLayout = "2006-01-02"
+
+// AnalyzeGDD downloads the daily or hourly data of a station and prints the growing
+// degree days of each day from --from to --to, accumulated from --from
+func AnalyzeGDD(c *cli.Context) error {
+	interval := climatedata.IntervalString(c.String("interval"))
+	if interval != climatedata.Daily && interval != climatedata.Hourly {
+		return fmt.Errorf("invalid interval: %s, must be daily or hourly", c.String("interval"))
+	}
+	method := climatedata.DegreeDayMethodString(c.String("method"))
+	if method == 0 {
+		return fmt.Errorf("invalid method: %s, must be average or sine", c.String("method"))
+	}
+	format := c.String("format")
+	if format != "table" && format != "csv" && format != "json" {
+		return fmt.Errorf("unknown format %q, must be table, csv or json", format)
+	}
+
+	from, err := time.Parse(dateLayout, c.String("from"))
+	if err != nil {
+		return fmt.Errorf("failed to parse --from: %w", err)
+	}
+	to, err := time.Parse(dateLayout, c.String("to"))
+	if err != nil {
+		return fmt.Errorf("failed to parse --to: %w", err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("--to %s is before --from %s", c.String("to"), c.String("from"))
+	}
+
+	stn := c.Int("stn")
+	s, ok := climatedata.Inventory().Station(stn)
+	if !ok {
+		return fmt.Errorf("station %d not found", stn)
+	}
+	s.WithClient(newClient(c))
+
+	dd := climatedata.NewDegreeDays(c.Float64("base")).Method(method)
+	if c.IsSet("cap") {
+		dd.Cap(c.Float64("cap"))
+	}
+	if c.Bool("heating") {
+		dd.Heating()
+	}
+
+	progress := s.RetreiveTimeframe(c.Context,
+		climatedata.Timeframe{Year: from.Year(), Month: 1, Day: 1},
+		climatedata.Timeframe{Year: to.Year(), Month: 12, Day: 31},
+		interval,
+	)
+	err = waitDownload(progress)
+	if err != nil {
+		return err
+	}
+
+	data, err := dd.Compute(s.XML.Data)
+	if err != nil {
+		return err
+	}
+	days := data.Between(from, to)
+	total := data.Total(from, to)
+
+	switch format {
+	case "csv":
+		err = days.CSV(os.Stdout)
+		if err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			StationID int                       `json:"stationID"`
+			From      string                    `json:"from"`
+			To        string                    `json:"to"`
+			Base      float64                   `json:"base"`
+			Method    string                    `json:"method"`
+			Total     climatedata.Measurement   `json:"total"`
+			Days      climatedata.DegreeDayData `json:"days"`
+		}{s.StationID, c.String("from"), c.String("to"), c.Float64("base"), method.String(), total, days})
+		if err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+	default:
+		fmt.Println("Date\t\tDegree Days\tAccumulated")
+		for _, a := range days {
+			fmt.Printf("%s\t%s\t\t%s\n", a.Timeframe().Time.Format(dateLayout), measurement(a.DegreeDays), measurement(a.Accumulated))
+		}
+		fmt.Printf("Total from %s to %s: %s\n", c.String("from"), c.String("to"), measurement(total))
+	}
+	return nil
+}
+
+// waitDownload waits for the download to finish, the datasets that failed are reported
+func waitDownload(progress climatedata.DownloadStatus) error {
+	for {
+		select {
+		case <-progress.Progress:
+		case t := <-progress.Done:
+			for _, f := range progress.Failed() {
+				fmt.Fprintf(os.Stderr, "Failed to download %s %d: %s\n", time.Month(f.Month), f.Year, f.Err)
+			}
+			if !t {
+				return fmt.Errorf("download did not complete")
+			}
+			return nil
+		}
+	}
+}
+
+// measurement formats a measurement of a table with its flag, - if it has no data
+func measurement(m climatedata.Measurement) string {
+	if !m.Valid {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%s", m.Value, m.Symbol())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	climatedata "github.com/cleanflo/open_data/weather_gc_ca"
+	"github.com/urfave/cli/v2"
+)
+
+// defaultCacheDir returns the directory used to cache downloaded datasets
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "climate-data")
+}
+
+func fileCache(c *cli.Context) *climatedata.FileCache {
+	cache := climatedata.NewFileCache(c.String("cache-dir"))
+	if d := c.Duration("max-age"); d > 0 {
+		cache.MaxAge = d
+	}
+	return cache
+}
+
+func CacheList(c *cli.Context) error {
+	entries, err := fileCache(c).List()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Station\tInterval\tPeriod\t\tSize\tCached\t\t\tExpired")
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+		fmt.Printf("%d\t%s\t\t%d-%02d\t\t%d\t%s\t%t\n",
+			e.Key.StationID, e.Key.Interval, e.Key.Year, e.Key.Month,
+			e.Size, e.Cached.Format("2006-01-02 15:04:05"), e.Expired)
+	}
+	fmt.Printf("%d datasets, %d bytes in %s\n", len(entries), size, c.String("cache-dir"))
+
+	return nil
+}
+
+func CachePrune(c *cli.Context) error {
+	n, err := fileCache(c).Prune()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d expired datasets\n", n)
+	return nil
+}
+
+func CacheClear(c *cli.Context) error {
+	n, err := fileCache(c).Clear()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removed %d datasets\n", n)
+	return nil
+}
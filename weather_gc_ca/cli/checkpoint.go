@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	climatedata "github.com/cleanflo/open_data/weather_gc_ca"
+)
+
+// checkpoint is a sidecar file next to the output file recording each dataset as it is
+// downloaded, so an interrupted download can be resumed. The first line is the header
+// describing the download, each following line is the JSON of one dataset
+type checkpoint struct {
+	path string
+	file *os.File
+	enc  *json.Encoder
+}
+
+type checkpointHeader struct {
+	StationID int                  `json:"stationID"`
+	Interval  climatedata.Interval `json:"interval"`
+	Start     int                  `json:"start"`
+	End       int                  `json:"end"`
+}
+
+func checkpointPath(output string) string {
+	return output + ".partial"
+}
+
+// createCheckpoint starts a new checkpoint, replacing any existing file
+func createCheckpoint(path string, h checkpointHeader) (*checkpoint, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+
+	c := &checkpoint{path: path, file: f, enc: json.NewEncoder(f)}
+	err = c.enc.Encode(h)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return c, nil
+}
+
+// resumeCheckpoint reads the datasets of an existing checkpoint and continues appending to it,
+// a new checkpoint is created if none exists. A checkpoint for a different download is an error
+func resumeCheckpoint(path string, h checkpointHeader) (*checkpoint, climatedata.StationDataXML, error) {
+	data := climatedata.NewStationData(h.Interval)
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if errors.Is(err, os.ErrNotExist) {
+		c, err := createCheckpoint(path, h)
+		return c, data, err
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	existing := checkpointHeader{}
+	err = json.Unmarshal(line, &existing)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if existing != h {
+		f.Close()
+		return nil, nil, fmt.Errorf("checkpoint %s is for station %d %s %d-%d, not this download",
+			path, existing.StationID, existing.Interval, existing.Start, existing.End)
+	}
+
+	// offset of the end of the last complete dataset, a dataset cut off by
+	// the interruption is discarded
+	offset := int64(len(line))
+	for {
+		line, err = r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+
+		d := climatedata.NewStationData(h.Interval)
+		if json.Unmarshal(line, d) != nil {
+			break
+		}
+		data.Append(d)
+		offset += int64(len(line))
+	}
+
+	err = f.Truncate(offset)
+	if err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to resume checkpoint: %w", err)
+	}
+
+	return &checkpoint{path: path, file: f, enc: json.NewEncoder(f)}, data, nil
+}
+
+// write appends the dataset to the checkpoint
+func (c *checkpoint) write(data climatedata.StationDataXML) error {
+	err := c.enc.Encode(data)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return c.file.Sync()
+}
+
+func (c *checkpoint) close() error {
+	return c.file.Close()
+}
+
+// remove deletes the checkpoint once the download is complete
+func (c *checkpoint) remove() error {
+	c.file.Close()
+	return os.Remove(c.path)
+}
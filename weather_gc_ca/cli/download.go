@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	climatedata "github.com/cleanflo/open_data/weather_gc_ca"
@@ -12,19 +14,12 @@ import (
 )
 
 func DownloadData(c *cli.Context) error {
-	var interval climatedata.Interval
-	switch c.String("interval") {
-	case "hourly":
-		interval = climatedata.Hourly
-	case "daily":
-		interval = climatedata.Daily
-	case "monthly":
-		interval = climatedata.Monthly
-	default:
+	interval := climatedata.IntervalString(c.String("interval"))
+	if interval == 0 {
 		return fmt.Errorf("invalid interval: %s", c.String("interval"))
 	}
 
-	stn := c.Int("station")
+	stn := c.Int("stn")
 	start := climatedata.Timeframe{
 		Year:  c.Int("start"),
 		Month: 1,
@@ -36,30 +31,53 @@ func DownloadData(c *cli.Context) error {
 		Day:   31,
 	}
 
-	s, ok := climatedata.StationInventory.Station(stn)
+	s, ok := climatedata.Inventory().Station(stn)
 	if !ok {
 		return fmt.Errorf("station %d not found", stn)
 	}
 
+	s.WithClient(newClient(c))
+
 	startYear, endYear := s.Timeframe(interval)
 	if start.Year == 0 {
 		start.Year = startYear
 	} else {
 		if start.Year < startYear {
-			return fmt.Errorf("provided start year %d is before station start year %d", start, startYear)
+			return fmt.Errorf("provided start year %d is before station start year %d", start.Year, startYear)
 		}
 	}
 	if end.Year == 0 {
 		end.Year = endYear
 	} else {
 		if end.Year > endYear {
-			return fmt.Errorf("provided end year %d is after station end year %d", end, endYear)
+			return fmt.Errorf("provided end year %d is after station end year %d", end.Year, endYear)
+		}
+	}
+
+	utc := false
+	switch t := strings.ToLower(c.String("time")); t {
+	case "", "lst":
+	case "utc":
+		utc = true
+	default:
+		return fmt.Errorf("invalid time: %s", c.String("time"))
+	}
+
+	// the data is resampled into a coarser interval once downloaded
+	output := interval
+	if r := c.String("resample"); r != "" {
+		output = climatedata.IntervalString(r)
+		if (output != climatedata.Daily && output != climatedata.Monthly) || output <= interval {
+			return fmt.Errorf("cannot resample %s data to %s", interval, r)
 		}
 	}
+	resampler := climatedata.NewResampler().
+		DailyCompleteness(c.Float64("daily-completeness")).
+		MonthlyCompleteness(c.Float64("monthly-completeness"))
 
 	p := c.Path("output")
 	if p == "" {
-		p = fmt.Sprintf("./%s_%d_%s_%d-%d.csv", s.Name, s.StationID, interval, start.Year, end.Year)
+		p = fmt.Sprintf("./%s_%d_%s_%d-%d.csv", s.Name, s.StationID, output, start.Year, end.Year)
 	}
 
 	outputFile, err := os.Create(p)
@@ -69,45 +87,164 @@ func DownloadData(c *cli.Context) error {
 
 	defer outputFile.Close()
 
+	// each dataset is recorded in the checkpoint as it arrives so an
+	// interrupted download can be continued with --resume
+	header := checkpointHeader{
+		StationID: s.StationID,
+		Interval:  interval,
+		Start:     start.Year,
+		End:       end.Year,
+	}
+	var cp *checkpoint
+	if c.Bool("resume") {
+		var data climatedata.StationDataXML
+		cp, data, err = resumeCheckpoint(checkpointPath(p), header)
+		if err != nil {
+			return err
+		}
+		if !data.Empty() {
+			first, last := data.Timeframe()
+			fmt.Printf("Resuming download, data from %s to %s already downloaded\n", first, last)
+		}
+		s.XML.Data = data
+	} else {
+		cp, err = createCheckpoint(checkpointPath(p), header)
+		if err != nil {
+			return err
+		}
+	}
+	defer cp.close()
+
 	fmt.Printf("Downloading %s data for station %d from %s to %s\n", interval, stn, start, end)
 
 	childCtx, cancel := context.WithCancel(c.Context)
+	defer cancel()
 	progress := s.RetreiveTimeframe(childCtx, start, end, interval)
 
 	ch := make(chan os.Signal, 1)
 	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
+	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught, the checkpoint
+	// still allows those downloads to be resumed
 	signal.Notify(ch, os.Interrupt)
+	defer signal.Stop(ch)
 	for {
 		select {
 		case <-ch:
 			fmt.Println("Received SIGINT, stopping...")
 			cancel()
-			os.Exit(0)
-		case <-c.Context.Done():
-			fmt.Println("context cancelled")
-			return nil
 		case p := <-progress.Progress:
 			if p.Error != nil {
 				fmt.Printf("Error: %s\n", p.Error)
 				continue
 			}
+			err = cp.write(p.Data)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+			}
 			fmt.Printf("Finished downloading data (%d / %d) upto: %s %d\n", p.Count, p.Total, time.Month(p.Timeframe.Month), p.Timeframe.Year)
 		case t := <-progress.Done:
-			if t {
+			failed := progress.Failed()
+			if t && len(failed) == 0 {
 				fmt.Println("Download complete")
 			} else {
 				fmt.Println("Download did not complete!")
 			}
+			for _, f := range failed {
+				fmt.Printf("Failed to download %s %d: %s\n", time.Month(f.Month), f.Year, f.Err)
+			}
+
+			if flags := c.String("exclude-flags"); flags != "" {
+				s.XML.Data = s.XML.Data.Exclude(strings.Split(flags, ",")...)
+			}
+			if utc {
+				s.XML.Data = climatedata.InUTC(s.XML.Data)
+			}
+			if output != interval {
+				s.XML.Data, err = resampler.Resample(s.XML.Data, output)
+				if err != nil {
+					return err
+				}
+			}
 
 			err = s.CSV(outputFile)
 			if err != nil {
 				return fmt.Errorf("failed to write CSV: %w", err)
 			}
 			fmt.Println("CSV written to", p)
-			return nil
-		default:
-			time.Sleep(time.Millisecond * 200)
+
+			if t && len(failed) == 0 {
+				return cp.remove()
+			}
+			// the checkpoint is kept to resume the periods that were not downloaded
+			if len(failed) > 0 {
+				return fmt.Errorf("download incomplete: %d periods failed, run the same command with --resume to continue", len(failed))
+			}
+			return fmt.Errorf("download incomplete: run the same command with --resume to continue")
 		}
 	}
 }
+
+// clientFlags are the flags of the commands downloading data, see newClient
+func clientFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "base-url",
+			Value: climatedata.DefaultBaseURL,
+			Usage: "bulk data endpoint to download from, eg. a mirror",
+		},
+		&cli.StringFlag{
+			Name:  "user-agent",
+			Usage: "User-Agent header sent with each request",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "timeout for each request, 0 for no timeout",
+		},
+		&cli.IntFlag{
+			Name:    "concurrency",
+			Aliases: []string{"c"},
+			Value:   climatedata.DefaultConcurrency,
+			Usage:   "number of requests to make at once",
+		},
+		&cli.Float64Flag{
+			Name:  "rate-limit",
+			Usage: "maximum requests per second, 0 for no limit",
+		},
+		&cli.IntFlag{
+			Name:  "retries",
+			Value: climatedata.DefaultRetries,
+			Usage: "number of times a failed request is retried",
+		},
+		&cli.StringFlag{
+			Name:  "cache-dir",
+			Value: defaultCacheDir(),
+			Usage: "directory to cache downloaded datasets in",
+		},
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "download every dataset without using the cache",
+		},
+		&cli.DurationFlag{
+			Name:  "max-age",
+			Value: climatedata.DefaultCacheMaxAge,
+			Usage: "max age of a cached dataset for the current period",
+		},
+	}
+}
+
+// newClient returns the client configured by the clientFlags
+func newClient(c *cli.Context) *climatedata.Client {
+	client := climatedata.NewClient().
+		HTTPClient(&http.Client{Timeout: c.Duration("timeout")}).
+		UserAgent(c.String("user-agent")).
+		Concurrency(c.Int("concurrency")).
+		RateLimit(c.Float64("rate-limit")).
+		Retries(c.Int("retries"))
+	if u := c.String("base-url"); u != "" {
+		client.BaseURL(u)
+	}
+	if !c.Bool("no-cache") {
+		client.Cache(fileCache(c))
+	}
+	return client
+}
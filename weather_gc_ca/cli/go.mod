@@ -1,4 +1,4 @@
-module main
+module github.com/cleanflo/open_data/weather_gc_ca/cli
 
 go 1.16
 
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	climatedata "github.com/cleanflo/open_data/weather_gc_ca"
+	"github.com/urfave/cli/v2"
+)
+
+// InventoryUpdate regenerates the station inventory json from the Station Inventory CSV,
+// the json is embedded in the package when it is rebuilt
+func InventoryUpdate(c *cli.Context) error {
+	stations, err := climatedata.LoadInventoryCSV(c.Path("file"))
+	if err != nil {
+		return err
+	}
+
+	// the json is written beside the output and renamed so the inventory is never left partial
+	output := c.Path("output")
+	f, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create inventory: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = stations.InventoryJSON(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	err = os.Rename(f.Name(), output)
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	fmt.Printf("Wrote %d stations to %s, rebuild to embed the inventory\n", len(stations), output)
+	return nil
+}
+
+// InventoryDiff prints the changes from an inventory to another, each inventory is a
+// Station Inventory CSV or an inventory json
+func InventoryDiff(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("must specify the old and new inventories")
+	}
+
+	previous, err := climatedata.LoadInventory(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	current, err := climatedata.LoadInventory(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	d := climatedata.DiffInventory(previous, current)
+	switch c.String("format") {
+	case "table":
+		fmt.Print(d)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(d)
+		if err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format %q, must be table or json", c.String("format"))
+	}
+	return nil
+}
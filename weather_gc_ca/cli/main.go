@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 
 	"github.com/urfave/cli/v2"
@@ -15,7 +16,15 @@ func init() {
 }
 
 func main() {
-	app := &cli.App{
+	err := newApp().Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newApp returns the commands of the CLI
+func newApp() *cli.App {
+	return &cli.App{
 		Name:  "climate data (climate.weather.gc.ca) CLI",
 		Usage: "a tool for downloading climate data from the government of canada",
 		Description: `
@@ -23,9 +32,18 @@ func main() {
 	climate search distance --lat 45 --lon 123 --max 25
 OR
 	climate search name --contains calgary --max 5
+OR
+	climate search radius --lat 45 --lon -75 --radius 50
+OR
+	climate search bbox --min-lat 45 --min-lon -76 --max-lat 46 --max-lon -75
+OR
+	climate search polygon --file area.geojson
 
 2. Download data:
 	climate download --stn 1234 --interval daily --start 1970 --end 2021
+
+3. Analyze data:
+	climate analyze gdd --stn 1234 --from 2021-04-01 --to 2021-10-31 --base 5 --cap 30
 `,
 		Commands: []*cli.Command{
 			{
@@ -40,13 +58,46 @@ OR
 					&cli.StringFlag{
 						Name:    "sort",
 						Aliases: []string{"s"},
-						Usage:   "sort by field: distance, name, hourly, daily, monthly",
+						Usage:   "sort by field: distance, name, hourly, daily, monthly, relevance",
 					},
 					&cli.IntFlag{
 						Name:    "interval",
 						Aliases: []string{"int", "i"},
 						Usage:   "The desired interval of data: hourly(0), daily(1), monthly(2)",
 					},
+					&cli.IntFlag{
+						Name:  "offset",
+						Usage: "number of stations to skip, to page through the results",
+					},
+					&cli.StringSliceFlag{
+						Name:    "province",
+						Aliases: []string{"p"},
+						Usage:   "only stations in the province, can be repeated",
+					},
+					&cli.IntSliceFlag{
+						Name:  "id",
+						Usage: "only the station with the id, can be repeated",
+					},
+					&cli.Float64Flag{
+						Name:  "min-elevation",
+						Usage: "only stations at or above the elevation in meters",
+					},
+					&cli.Float64Flag{
+						Name:  "max-elevation",
+						Usage: "only stations at or below the elevation in meters",
+					},
+					&cli.IntFlag{
+						Name:  "from",
+						Usage: "only stations with data for the interval in every year from this year to --to",
+					},
+					&cli.IntFlag{
+						Name:  "to",
+						Usage: "only stations with data for the interval in every year from --from to this year",
+					},
+					&cli.IntFlag{
+						Name:  "min-years",
+						Usage: "with --from and --to, only stations with data in at least this many of the years",
+					},
 				},
 				Subcommands: []*cli.Command{
 					{
@@ -62,6 +113,11 @@ OR
 								Name:  "starts-with",
 								Usage: "search for stations starting with this string",
 							},
+							&cli.StringFlag{
+								Name:  "fuzzy",
+								Usage: "search for stations matching this string, ignoring accents, punctuation and typos, most relevant first",
+							},
+							formatFlag(),
 						},
 						Action: SearchByName,
 					},
@@ -82,21 +138,106 @@ OR
 								Usage:    "Longitude of the coordinate pair",
 								Required: true,
 							},
+							formatFlag(),
 						},
 						Action: SearchByCoor,
 					},
+					{
+						Name:    "radius",
+						Aliases: []string{"r"},
+						Usage:   "output the stations within a radius of a given coordinate pair",
+						Flags: []cli.Flag{
+							&cli.Float64Flag{
+								Name:     "latitude",
+								Aliases:  []string{"lat"},
+								Usage:    "Latitude of the coordinate pair",
+								Required: true,
+							},
+							&cli.Float64Flag{
+								Name:     "longitude",
+								Aliases:  []string{"lon", "lng"},
+								Usage:    "Longitude of the coordinate pair",
+								Required: true,
+							},
+							&cli.Float64Flag{
+								Name:     "radius",
+								Aliases:  []string{"km"},
+								Usage:    "Radius of the search in km",
+								Required: true,
+							},
+							formatFlag(),
+						},
+						Action: SearchByRadius,
+					},
+					{
+						Name:    "bbox",
+						Aliases: []string{"b"},
+						Usage:   "output the stations inside a bounding box",
+						Flags: []cli.Flag{
+							&cli.Float64Flag{
+								Name:     "min-lat",
+								Usage:    "Southern latitude of the box",
+								Required: true,
+							},
+							&cli.Float64Flag{
+								Name:     "min-lon",
+								Aliases:  []string{"min-lng"},
+								Usage:    "Western longitude of the box",
+								Required: true,
+							},
+							&cli.Float64Flag{
+								Name:     "max-lat",
+								Usage:    "Northern latitude of the box",
+								Required: true,
+							},
+							&cli.Float64Flag{
+								Name:     "max-lon",
+								Aliases:  []string{"max-lng"},
+								Usage:    "Eastern longitude of the box, less than min-lon if the box crosses the antimeridian",
+								Required: true,
+							},
+							formatFlag(),
+						},
+						Action: SearchByBBox,
+					},
+					{
+						Name:    "polygon",
+						Aliases: []string{"p"},
+						Usage:   "output the stations inside a GeoJSON Polygon or MultiPolygon",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Usage:    "GeoJSON file of the area, a geometry, Feature or FeatureCollection",
+								Required: true,
+							},
+							formatFlag(),
+						},
+						Action: SearchByPolygon,
+					},
 				},
 			},
 			{
 				Name:    "info",
 				Aliases: []string{"i"},
-				Usage:   "retreive info for a station by ID",
+				Usage:   "retreive info for a station by ID, Climate ID, WMO ID or TC ID",
 				Flags: []cli.Flag{
 					&cli.IntFlag{
-						Name:     "station id",
-						Aliases:  []string{"s", "stn", "id"},
-						Usage:    "Station ID to get info for",
-						Required: true,
+						Name:    "stn",
+						Aliases: []string{"s", "id"},
+						Usage:   "Station ID to get info for",
+					},
+					&cli.StringFlag{
+						Name:  "climate-id",
+						Usage: "Climate ID to get info for, eg. 6158359",
+					},
+					&cli.StringFlag{
+						Name:  "wmo",
+						Usage: "WMO ID to get info for, eg. 71265",
+					},
+					&cli.StringFlag{
+						Name:  "tc",
+						Usage: "Transport Canada ID to get info for, eg. YTZ",
 					},
 				},
 				Action: StationInfo,
@@ -104,10 +245,10 @@ OR
 			{
 				Name:  "download",
 				Usage: "download data for a station, if no start or end is supplied it will download the entire time range",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.IntFlag{
-						Name:     "station id",
-						Aliases:  []string{"s", "stn", "id"},
+						Name:     "stn",
+						Aliases:  []string{"s", "id"},
 						Usage:    "Station ID to get info for",
 						Required: true,
 					},
@@ -120,7 +261,7 @@ OR
 						Name:    "interval",
 						Aliases: []string{"i", "int"},
 						Value:   "daily",
-						Usage:   "interval to download data for: hourly, daily, monthly",
+						Usage:   "interval to download data for: hourly, daily, monthly, almanac",
 					},
 					&cli.IntFlag{
 						Name:  "start",
@@ -130,68 +271,351 @@ OR
 						Name:  "end",
 						Usage: "ending year to download data for the station",
 					},
-				},
+				}, append(clientFlags(),
+					&cli.StringFlag{
+						Name:  "exclude-flags",
+						Usage: "comma separated flags of the values to leave out of the output, eg. M,E",
+					},
+					&cli.StringFlag{
+						Name:  "time",
+						Usage: "time of the hourly data in the output, lst (local standard time of the station) or utc",
+						Value: "lst",
+					},
+					&cli.StringFlag{
+						Name:  "resample",
+						Usage: "aggregate the data into a coarser interval in the output: daily (from hourly) or monthly (from hourly or daily)",
+					},
+					&cli.Float64Flag{
+						Name:  "daily-completeness",
+						Value: climatedata.DefaultDailyCompleteness,
+						Usage: "share of the hours of a day with data needed for a resampled daily value",
+					},
+					&cli.Float64Flag{
+						Name:  "monthly-completeness",
+						Value: climatedata.DefaultMonthlyCompleteness,
+						Usage: "share of the days of a month with data needed for a resampled monthly value",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "continue an interrupted download from its checkpoint, skipping the data already downloaded",
+					},
+				)...),
 				Action: DownloadData,
 			},
+			{
+				Name:  "analyze",
+				Usage: "analyze the data of a station",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "gdd",
+						Usage: "degree days of each day from a date to another, accumulated from the first date",
+						Flags: append([]cli.Flag{
+							&cli.IntFlag{
+								Name:     "stn",
+								Aliases:  []string{"s", "id"},
+								Usage:    "Station ID to analyze",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "from",
+								Usage:    "first day of the accumulation, YYYY-MM-DD",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "to",
+								Usage:    "last day of the accumulation, YYYY-MM-DD",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:    "interval",
+								Aliases: []string{"i", "int"},
+								Value:   "daily",
+								Usage:   "interval of the data to compute the degree days from: daily or hourly",
+							},
+							&cli.Float64Flag{
+								Name:  "base",
+								Value: 5,
+								Usage: "base temperature in °C, the degrees above it are counted",
+							},
+							&cli.Float64Flag{
+								Name:  "cap",
+								Usage: "upper temperature in °C, the temperatures above it are counted as the cap",
+							},
+							&cli.StringFlag{
+								Name:  "method",
+								Value: "average",
+								Usage: "method of the daily data: average of the maximum and minimum, or single sine",
+							},
+							&cli.BoolFlag{
+								Name:  "heating",
+								Usage: "count the degrees below the base, the heating degree days",
+							},
+							&cli.StringFlag{
+								Name:  "format",
+								Value: "table",
+								Usage: "output format: table, csv or json",
+							},
+						}, clientFlags()...),
+						Action: AnalyzeGDD,
+					},
+				},
+			},
+			{
+				Name:  "inventory",
+				Usage: "manage the station inventory",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "update",
+						Usage: "regenerate the embedded station-inventory.json from the Station Inventory CSV",
+						Flags: []cli.Flag{
+							&cli.PathFlag{
+								Name:     "file",
+								Aliases:  []string{"f"},
+								Usage:    "the Station Inventory CSV published by Environment Canada",
+								Required: true,
+							},
+							&cli.PathFlag{
+								Name:    "output",
+								Aliases: []string{"o"},
+								Value:   "station-inventory.json",
+								Usage:   "the inventory json to write, the station-inventory.json of the package to embed it",
+							},
+						},
+						Action: InventoryUpdate,
+					},
+					{
+						Name:      "diff",
+						Usage:     "list the stations added, removed, closed, moved, extended or modified between two inventories",
+						ArgsUsage: "old.json new.csv",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "format",
+								Value: "table",
+								Usage: "output format: table or json keyed by station id",
+							},
+						},
+						Action: InventoryDiff,
+					},
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "manage the cache of downloaded datasets",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "cache-dir",
+						Value: defaultCacheDir(),
+						Usage: "directory the datasets are cached in",
+					},
+					&cli.DurationFlag{
+						Name:  "max-age",
+						Value: climatedata.DefaultCacheMaxAge,
+						Usage: "max age of a cached dataset for the current period",
+					},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "ls",
+						Usage:  "list the cached datasets",
+						Action: CacheList,
+					},
+					{
+						Name:   "prune",
+						Usage:  "remove the expired datasets",
+						Action: CachePrune,
+					},
+					{
+						Name:   "clear",
+						Usage:  "remove every cached dataset",
+						Action: CacheClear,
+					},
+				},
+			},
 		},
 	}
-
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
-	}
 }
 
 func SearchByName(c *cli.Context) error {
 	contains := c.String("contains")
 	startsWith := c.String("starts-with")
-	max := c.Int("max-count")
+	fuzzy := c.String("fuzzy")
 
-	if contains != "" && startsWith != "" {
-		return fmt.Errorf("cannot specify both --contains and --starts-with")
+	set := 0
+	for _, a := range []string{contains, startsWith, fuzzy} {
+		if a != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("cannot specify more than one of --contains, --starts-with or --fuzzy")
 	}
 
-	if contains == "" && startsWith == "" {
-		return fmt.Errorf("must specify either --contains or --starts-with")
+	if set == 0 {
+		return fmt.Errorf("must specify one of --contains, --starts-with or --fuzzy")
 	}
 
-	var stations climatedata.RawStations
-	if contains != "" {
-		stations = climatedata.StationInventory.NameContains(contains, max)
-	} else if startsWith != "" {
-		stations = climatedata.StationInventory.NameStartsWith(startsWith, max)
+	q, err := searchQuery(c)
+	if err != nil {
+		return err
+	}
+	q.Limit(c.Int("max-count"))
+	switch {
+	case contains != "":
+		q.NameContains(contains)
+	case startsWith != "":
+		q.NameStartsWith(startsWith)
+	case fuzzy != "":
+		q.NameFuzzy(fuzzy)
 	}
-	stations.Sort(climatedata.SortByString(c.String("sort")))
-	fmt.Println(stations)
 
-	return nil
+	return printStations(c, climatedata.Inventory().Query(q))
 }
 
 func SearchByCoor(c *cli.Context) error {
 	lat := c.Float64("latitude")
 	lng := c.Float64("longitude")
-	max := c.Int("max-count")
-	interval := climatedata.Interval(c.Int("interval"))
-	stations := climatedata.StationInventory.FindWithInterval(lat, lng, max, interval)
+	q, err := searchQuery(c)
+	if err != nil {
+		return err
+	}
+	q.Near(lat, lng).Limit(c.Int("max-count"))
+
+	return printStations(c, climatedata.Inventory().Query(q))
+}
+
+func SearchByRadius(c *cli.Context) error {
+	lat := c.Float64("latitude")
+	lng := c.Float64("longitude")
+	q, err := searchQuery(c)
+	if err != nil {
+		return err
+	}
+	limit(c, q.Within(lat, lng, c.Float64("radius")))
+
+	return printStations(c, climatedata.Inventory().Query(q))
+}
+
+func SearchByBBox(c *cli.Context) error {
+	q, err := searchQuery(c)
+	if err != nil {
+		return err
+	}
+	limit(c, q.BBox(
+		c.Float64("min-lat"), c.Float64("min-lon"),
+		c.Float64("max-lat"), c.Float64("max-lon"),
+	))
+
+	return printStations(c, climatedata.Inventory().Query(q))
+}
+
+func SearchByPolygon(c *cli.Context) error {
+	b, err := os.ReadFile(c.Path("file"))
+	if err != nil {
+		return fmt.Errorf("failed to read polygon: %w", err)
+	}
 
-	sort := c.String("sort")
-	if sort == "" {
-		sort = "distance"
+	polygon, err := climatedata.ParsePolygon(b)
+	if err != nil {
+		return err
+	}
+
+	q, err := searchQuery(c)
+	if err != nil {
+		return err
+	}
+	limit(c, q.Polygon(polygon))
+	return printStations(c, climatedata.Inventory().Query(q))
+}
+
+// searchQuery returns the query of the flags shared by the search subcommands, the stations
+// are sorted by the sort flag if it is set or by the default of the query otherwise
+func searchQuery(c *cli.Context) (*climatedata.StationQuery, error) {
+	q := climatedata.NewStationQuery().
+		Interval(climatedata.Interval(c.Int("interval"))).
+		Offset(c.Int("offset"))
+
+	if provinces := c.StringSlice("province"); len(provinces) > 0 {
+		q.Province(provinces...)
+	}
+	if ids := c.IntSlice("id"); len(ids) > 0 {
+		q.IDs(ids...)
+	}
+	if c.IsSet("min-elevation") || c.IsSet("max-elevation") {
+		min, max := math.Inf(-1), math.Inf(1)
+		if c.IsSet("min-elevation") {
+			min = c.Float64("min-elevation")
+		}
+		if c.IsSet("max-elevation") {
+			max = c.Float64("max-elevation")
+		}
+		q.Elevation(min, max)
+	}
+	if c.IsSet("from") || c.IsSet("to") {
+		if !c.IsSet("from") || !c.IsSet("to") {
+			return nil, fmt.Errorf("must specify both --from and --to")
+		}
+		q.Overlapping(c.Int("from"), c.Int("to"), c.Int("min-years"))
+	}
+	if c.IsSet("sort") {
+		q.Sort(climatedata.SortByString(c.String("sort")))
 	}
+	return q, nil
+}
+
+// limit limits the query to max-count stations if the flag is set, every station is returned otherwise
+func limit(c *cli.Context, q *climatedata.StationQuery) *climatedata.StationQuery {
+	if c.IsSet("max-count") {
+		q.Limit(c.Int("max-count"))
+	}
+	return q
+}
+
+// formatFlag is the output format of the search subcommands
+func formatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"o"},
+		Value:   "table",
+		Usage:   "output format: table, csv, json, geojson or ndjson",
+	}
+}
 
-	stations.Sort(climatedata.SortByString(sort))
+// printStations writes the stations found in the format flag, they are sorted by the query
+func printStations(c *cli.Context, stations climatedata.RawStations) error {
+	if format := c.String("format"); format != "" && format != "table" {
+		return stations.Write(os.Stdout, format)
+	}
 	fmt.Println(stations)
 
 	return nil
 }
 
 func StationInfo(c *cli.Context) error {
-	stn := c.Int("station")
-	station, ok := climatedata.StationInventory.Station(stn)
-	if !ok {
-		return fmt.Errorf("station %d not found", stn)
+	var stations climatedata.RawStations
+	switch {
+	case c.IsSet("climate-id"):
+		stations = climatedata.Inventory().StationsByClimateID(c.String("climate-id"))
+	case c.IsSet("wmo"):
+		stations = climatedata.Inventory().StationsByWMOID(c.String("wmo"))
+	case c.IsSet("tc"):
+		stations = climatedata.Inventory().StationsByTCID(c.String("tc"))
+	case c.IsSet("stn"):
+		stn := c.Int("stn")
+		station, ok := climatedata.Inventory().Station(stn)
+		if !ok {
+			return fmt.Errorf("station %d not found", stn)
+		}
+		stations = climatedata.RawStations{station}
+	default:
+		return fmt.Errorf("must specify one of --stn, --climate-id, --wmo or --tc")
 	}
 
-	fmt.Println(station)
+	if len(stations) == 0 {
+		return fmt.Errorf("no station found")
+	}
+
+	for i := range stations {
+		fmt.Println(&stations[i])
+	}
 	return nil
 }
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	climatedata "github.com/cleanflo/open_data/weather_gc_ca"
+)
+
+func TestStationInfo(t *testing.T) {
+	prev := climatedata.Inventory()
+	climatedata.SetInventory(climatedata.RawStations{{Name: "TORONTO", Province: "ONTARIO", StationID: 5097}})
+	t.Cleanup(func() { climatedata.SetInventory(prev) })
+
+	for _, flag := range []string{"--stn", "--id", "-s"} {
+		err := newApp().Run([]string{"climate", "info", flag, "5097"})
+		if err != nil {
+			t.Errorf("info %s 5097: %s", flag, err)
+		}
+
+		err = newApp().Run([]string{"climate", "info", flag, "1"})
+		if err == nil || !strings.Contains(err.Error(), "station 1 not found") {
+			t.Errorf("info %s 1: expected station 1 not found, got %v", flag, err)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package weather_gc_ca
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the Environment Canada bulk data endpoint
+const DefaultBaseURL = "https://climate.weather.gc.ca/climate_data/bulk_data_e.html"
+
+// DefaultConcurrency is the number of requests a client makes at once during a download
+const DefaultConcurrency = 4
+
+// DefaultRetries is the number of times a failed request is retried
+const DefaultRetries = 3
+
+// DefaultBackoff is the delay before the first retry, it doubles for each retry upto DefaultMaxBackoff
+const (
+	DefaultBackoff    = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// DefaultClient is used by any station that has not been given a client with WithClient
+var DefaultClient = NewClient()
+
+// Client performs the requests to the bulk data endpoint, the base URL can be pointed
+// at a mirror or test server and the http.Client configured with timeouts, proxies, etc.
+type Client struct {
+	httpClient  *http.Client
+	baseURL     string
+	userAgent   string
+	concurrency int
+	limit       *limiter
+	retries     int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	cache       Cache
+}
+
+// NewClient returns a client using http.DefaultClient and DefaultBaseURL
+func NewClient() *Client {
+	return &Client{
+		httpClient:  http.DefaultClient,
+		baseURL:     DefaultBaseURL,
+		concurrency: DefaultConcurrency,
+		retries:     DefaultRetries,
+		backoffBase: DefaultBackoff,
+		backoffMax:  DefaultMaxBackoff,
+	}
+}
+
+func (c *Client) HTTPClient(h *http.Client) *Client {
+	c.httpClient = h
+	return c
+}
+
+func (c *Client) BaseURL(u string) *Client {
+	c.baseURL = u
+	return c
+}
+
+func (c *Client) UserAgent(a string) *Client {
+	c.userAgent = a
+	return c
+}
+
+// Concurrency sets the number of requests made at once during a download
+func (c *Client) Concurrency(n int) *Client {
+	c.concurrency = n
+	return c
+}
+
+// RateLimit limits the requests per second made by the client across all downloads,
+// 0 removes the limit
+func (c *Client) RateLimit(rps float64) *Client {
+	if rps <= 0 {
+		c.limit = nil
+		return c
+	}
+	c.limit = &limiter{interval: time.Duration(float64(time.Second) / rps)}
+	return c
+}
+
+// Retries sets the number of times a throttled, failed or malformed request is retried
+func (c *Client) Retries(n int) *Client {
+	c.retries = n
+	return c
+}
+
+// Backoff sets the delay before the first retry and the max delay between retries
+func (c *Client) Backoff(base, max time.Duration) *Client {
+	c.backoffBase = base
+	c.backoffMax = max
+	return c
+}
+
+// Cache sets the cache checked before each request and filled with each response,
+// nil disables caching
+func (c *Client) Cache(cache Cache) *Client {
+	c.cache = cache
+	return c
+}
+
+func (c *Client) workers() int {
+	if c.concurrency < 1 {
+		return 1
+	}
+	return c.concurrency
+}
+
+// limiter spaces requests at least interval apart
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next request is allowed or the context is done
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	t := l.next
+	if t.Before(now) {
+		t = now
+	}
+	l.next = t.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(t)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// request builds the bulk data request for the dataset
+//
+//	?format=xml&stationID=5097&Year=${year}&Month=${month}&Day=1&timeframe=2&submit= Download+Data
+func (c *Client) request(ctx context.Context, stationID, year, month, day int, interval Interval) (*http.Request, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %s", err)
+	}
+
+	q := u.Query()
+	q.Add("format", "xml")
+	q.Add("stationID", fmt.Sprintf("%d", stationID))
+	q.Add("Year", fmt.Sprintf("%d", year))
+	q.Add("Month", fmt.Sprintf("%d", month))
+	q.Add("Day", fmt.Sprintf("%d", day))
+	q.Add("timeframe", fmt.Sprintf("%d", interval))
+	q.Add("submit", " Download+Data")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	return req, nil
+}
+
+// retreive returns the cached dataset or downloads and decodes it, retrying throttled, server, network
+// and malformed responses with exponential backoff. The returned error is a *RequestError
+func (c *Client) retreive(ctx context.Context, stationID, year, month, day int, interval Interval) (StationDataXML, error) {
+	reqErr := &RequestError{
+		StationID: stationID,
+		Year:      year,
+		Month:     month,
+		Interval:  interval,
+	}
+
+	if c.cache != nil {
+		b, ok := c.cache.Get(CacheKey{StationID: stationID, Interval: interval, Year: year, Month: month})
+		if ok {
+			data, err := decodeDataset(b, interval)
+			if err == nil {
+				return data, nil
+			}
+		}
+	}
+
+	for {
+		reqErr.Attempts++
+		data, wait, err := c.attempt(ctx, stationID, year, month, day, interval)
+		if err == nil {
+			return data, nil
+		}
+		reqErr.Err = err
+
+		if reqErr.Attempts > c.retries || !retryable(err) || ctx.Err() != nil {
+			return nil, reqErr
+		}
+
+		if wait == 0 {
+			wait = c.backoff(reqErr.Attempts)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, reqErr
+		}
+	}
+}
+
+// attempt makes a single request for the dataset, wait is the delay requested
+// by the server in a Retry-After header
+func (c *Client) attempt(ctx context.Context, stationID, year, month, day int, interval Interval) (data StationDataXML, wait time.Duration, err error) {
+	req, err := c.request(ctx, stationID, year, month, day, interval)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = c.limit.wait(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h := c.httpClient
+	if h == nil {
+		h = http.DefaultClient
+	}
+
+	resp, err := h.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		return nil, 0, fmt.Errorf("%w: %s", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotFound, resp.Status)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return nil, retryAfter(resp), fmt.Errorf("%w: %s", ErrThrottled, resp.Status)
+	case resp.StatusCode >= 500:
+		return nil, 0, fmt.Errorf("%w: %s", ErrServer, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, 0, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	// errors are reported with an HTML page rather than a status code
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "text/html" {
+		return nil, 0, fmt.Errorf("%w: unexpected content type %s", ErrMalformedXML, contentType)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		return nil, 0, fmt.Errorf("%w: %s", ErrNetwork, err)
+	}
+
+	data, err = decodeDataset(b, interval)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if c.cache != nil {
+		// a failed cache write does not fail the download
+		_ = c.cache.Put(CacheKey{StationID: stationID, Interval: interval, Year: year, Month: month}, b)
+	}
+
+	return data, 0, nil
+}
+
+// decodeDataset decodes the bulk data XML into the dataset type of the interval
+func decodeDataset(b []byte, interval Interval) (StationDataXML, error) {
+	x := ClimateDataXML{Data: NewStationData(interval)}
+	err := xml.Unmarshal(b, &x)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedXML, err)
+	}
+
+	return x.Data, nil
+}
+
+// backoff returns the delay before the next attempt, doubling from the base delay
+// for each attempt upto the max delay with full jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.backoffBase << uint(attempt-1)
+	if d <= 0 || d > c.backoffMax {
+		d = c.backoffMax
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func retryable(err error) bool {
+	return errors.Is(err, ErrThrottled) ||
+		errors.Is(err, ErrServer) ||
+		errors.Is(err, ErrNetwork) ||
+		errors.Is(err, ErrMalformedXML)
+}
+
+// retryAfter parses the Retry-After header in seconds, 0 if not set
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,417 @@
+package weather_gc_ca
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDataFiles maps the bulk data timeframe parameter to the file served by newTestServer
+var testDataFiles = map[string]string{
+	"1": "./_testdata/test-hourly_toronto.xml",
+	"2": "./_testdata/test-daily_toronto.xml",
+	"3": "./_testdata/test-monthly_toronto.xml",
+	"4": "./_testdata/test-almanac_toronto.xml",
+}
+
+// newTestServer is a stand-in for the bulk data endpoint serving the _testdata files,
+// requests counts the requests received. The daily data only covers 1992, the other
+// years are served without data
+func newTestServer(t *testing.T) (s *httptest.Server, requests *int32) {
+	requests = new(int32)
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		q := r.URL.Query()
+		f, ok := testDataFiles[q.Get("timeframe")]
+		if !ok {
+			http.Error(w, "unknown timeframe", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		if q.Get("timeframe") == "2" && q.Get("Year") != "1992" {
+			fmt.Fprint(w, "<climatedata></climatedata>")
+			return
+		}
+		http.ServeFile(w, r, f)
+	}))
+	t.Cleanup(s.Close)
+	return s, requests
+}
+
+func newTestClient(t *testing.T) (*Client, *int32) {
+	s, requests := newTestServer(t)
+	return NewClient().HTTPClient(s.Client()).BaseURL(s.URL + "/climate_data/bulk_data_e.html"), requests
+}
+
+// useTestClient replaces the DefaultClient for the duration of the test
+func useTestClient(t *testing.T) *int32 {
+	c, requests := newTestClient(t)
+	prev := DefaultClient
+	DefaultClient = c
+	t.Cleanup(func() { DefaultClient = prev })
+	return requests
+}
+
+func TestClient(t *testing.T) {
+	t.Run("request", func(t *testing.T) {
+		c := NewClient().BaseURL("http://localhost/mirror?lang=en").UserAgent("open_data-test")
+		req, err := c.request(context.Background(), 5097, 1992, 3, 1, Daily)
+		assert.NoError(t, err)
+
+		q := req.URL.Query()
+		assert.Equal(t, "localhost", req.URL.Host)
+		assert.Equal(t, "/mirror", req.URL.Path)
+		assert.Equal(t, "en", q.Get("lang"))
+		assert.Equal(t, "xml", q.Get("format"))
+		assert.Equal(t, "5097", q.Get("stationID"))
+		assert.Equal(t, "1992", q.Get("Year"))
+		assert.Equal(t, "3", q.Get("Month"))
+		assert.Equal(t, "2", q.Get("timeframe"))
+		assert.Equal(t, "open_data-test", req.Header.Get("User-Agent"))
+	})
+
+	t.Run("invalid base url", func(t *testing.T) {
+		_, err := NewClient().BaseURL("://").retreive(context.Background(), 1, 1992, 1, 1, Daily)
+		assert.Error(t, err)
+	})
+
+	t.Run("retreive", func(t *testing.T) {
+		c, requests := newTestClient(t)
+		for interval, f := range map[Interval]func() StationDataXML{
+			Hourly:  func() StationDataXML { return &HourlyDataXML{} },
+			Daily:   func() StationDataXML { return &DailyDataXML{} },
+			Monthly: func() StationDataXML { return &MonthlyDataXML{} },
+			Almanac: func() StationDataXML { return &AlmanacDataXML{} },
+		} {
+			data, err := c.retreive(context.Background(), 51459, 1992, 1, 1, interval)
+			assert.NoErrorf(t, err, "interval %s", interval)
+			assert.IsTypef(t, f(), data, "interval %s", interval)
+			assert.Falsef(t, data.Empty(), "interval %s", interval)
+		}
+		assert.Equal(t, int32(4), atomic.LoadInt32(requests))
+	})
+}
+
+func TestRetreiveTimeframe(t *testing.T) {
+	c, requests := newTestClient(t)
+	s := StationMetadata{StationID: 51459, DailyFirstYear: 1992, DailyLastYear: 1993}
+	s.WithClient(c)
+
+	status := s.RetreiveDailyData(context.Background())
+	count := 0
+	for done := false; !done; {
+		select {
+		case p := <-status.Progress:
+			assert.NoError(t, p.Error)
+			assert.NotNil(t, p.Data)
+			assert.Equal(t, 1992, p.Timeframe.Year)
+			count++
+		case ok := <-status.Done:
+			assert.True(t, ok)
+			done = true
+		}
+	}
+
+	// the last year is included
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+	assert.Equal(t, 366, len(*s.XML.Data.(*DailyDataXML)))
+
+	b := &bytes.Buffer{}
+	assert.NoError(t, s.CSV(b))
+	rows, err := csv.NewReader(b).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, 367, len(rows))
+}
+
+func TestRetreiveMonthlyData(t *testing.T) {
+	c, requests := newTestClient(t)
+	s := StationMetadata{StationID: 30247, MonthlyFirstYear: 1990, MonthlyLastYear: 1999}
+	s.WithClient(c)
+
+	published := &MonthlyDataXML{}
+	decodeTestData(t, "./_testdata/test-monthly_toronto.xml", published)
+
+	for i := 0; i < 2; i++ {
+		status := s.RetreiveMonthlyData(context.Background())
+		for done := false; !done; {
+			select {
+			case p := <-status.Progress:
+				assert.NoError(t, p.Error)
+			case ok := <-status.Done:
+				assert.True(t, ok)
+				done = true
+			}
+		}
+
+		// the dataset is the whole period of record, it is requested once
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+		assert.Equal(t, len(*published), len(*s.XML.Data.(*MonthlyDataXML)))
+	}
+}
+
+func TestDownloadHandlerStream(t *testing.T) {
+	useTestClient(t)
+
+	t.Run("json", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/station/download/?stationID=30247&interval=almanac", nil)
+		w := httptest.NewRecorder()
+		DownloadHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		a := []AlmanacBaseXML{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &a))
+		assert.Equal(t, 366, len(a))
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/station/download/?stationID=30247&interval=almanac", nil)
+		req.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+		DownloadHandler(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, 367, len(rows))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest("GET", "/station/download/?stationID=30247&interval=almanac&format=csv", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		DownloadHandler(w, req)
+		assert.Equal(t, 0, w.Body.Len())
+	})
+}
+
+func TestConcurrentDownload(t *testing.T) {
+	// respond to later months first so the results arrive out of order
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		month, _ := strconv.Atoi(q.Get("Month"))
+		time.Sleep(time.Duration(12-month) * 5 * time.Millisecond)
+		fmt.Fprintf(w, `<climatedata><stationdata timetype="LST" day="1" hour="0" minute="0" month="%s" year="%s"><temp>1.0</temp></stationdata></climatedata>`,
+			q.Get("Month"), q.Get("Year"))
+	}))
+	defer s.Close()
+
+	c := NewClient().HTTPClient(s.Client()).BaseURL(s.URL).Concurrency(6)
+	st := StationMetadata{StationID: 51459, Province: "BRITISH COLUMBIA", Latitude: 49.96, Longitude: -119.38, HourlyFirstYear: 1992, HourlyLastYear: 1994}
+	status := st.WithClient(c).RetreiveHourlyData(context.Background())
+
+	count := 0
+	previous := time.Time{}
+	for done := false; !done; {
+		select {
+		case p := <-status.Progress:
+			count++
+			assert.NoError(t, p.Error)
+			assert.Equal(t, 36, p.Total)
+			assert.Equal(t, count, p.Count)
+			assert.Truef(t, p.Timeframe.Time.After(previous), "progress out of order: %s after %s", p.Timeframe, previous)
+			previous = p.Timeframe.Time
+		case ok := <-status.Done:
+			assert.True(t, ok)
+			done = true
+		}
+	}
+
+	assert.Equal(t, 36, count)
+	h := *st.XML.Data.(*HourlyDataXML)
+	assert.Equal(t, 36, len(h))
+	for i := 1; i < len(h); i++ {
+		assert.True(t, h[i].Timeframe().Time.After(h[i-1].Timeframe().Time))
+	}
+
+	// the datasets have no station information, the zone is from the inventory
+	_, offset := h[0].Local().Zone()
+	assert.Equal(t, -8*60*60, offset)
+}
+
+func TestRateLimit(t *testing.T) {
+	l := NewClient().RateLimit(50).limit
+	begin := time.Now()
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, l.wait(context.Background()))
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(begin)), int64(90*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l = NewClient().RateLimit(0.1).limit
+	assert.NoError(t, l.wait(ctx))
+	assert.Error(t, l.wait(ctx))
+
+	assert.Nil(t, NewClient().RateLimit(0).limit)
+}
+
+func TestRetry(t *testing.T) {
+	// respond with each status in turn, then serve the daily test data
+	newServer := func(statuses ...int) (*Client, *int32) {
+		requests := new(int32)
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := int(atomic.AddInt32(requests, 1))
+			if n <= len(statuses) {
+				switch statuses[n-1] {
+				case http.StatusTooManyRequests:
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				case -1: // error page
+					w.Header().Set("Content-Type", "text/html; charset=utf-8")
+					fmt.Fprint(w, "<html><body>Service unavailable</body></html>")
+				case -2: // truncated document
+					w.Header().Set("Content-Type", "text/xml")
+					fmt.Fprint(w, "<climatedata><stationdata day=")
+				default:
+					w.WriteHeader(statuses[n-1])
+				}
+				return
+			}
+			http.ServeFile(w, r, testDataFiles["2"])
+		}))
+		t.Cleanup(s.Close)
+		return NewClient().HTTPClient(s.Client()).BaseURL(s.URL).Retries(3).Backoff(time.Millisecond, 5*time.Millisecond), requests
+	}
+
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int32
+		err      error
+	}{
+		{"success", nil, 1, nil},
+		{"recovers from throttling", []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, 3, nil},
+		{"recovers from error page", []int{-1}, 2, nil},
+		{"recovers from truncated xml", []int{-2}, 2, nil},
+		{"not found is permanent", []int{http.StatusNotFound}, 1, ErrNotFound},
+		{"bad request is permanent", []int{http.StatusBadRequest}, 1, ErrRequestFailed},
+		{"server error exhausts retries", []int{500, 502, 500, 504}, 4, ErrServer},
+		{"error page exhausts retries", []int{-1, -1, -1, -1}, 4, ErrMalformedXML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, requests := newServer(tt.statuses...)
+			data, err := c.retreive(context.Background(), 51459, 1992, 1, 1, Daily)
+			assert.Equal(t, tt.requests, atomic.LoadInt32(requests))
+			if tt.err == nil {
+				assert.NoError(t, err)
+				assert.False(t, data.Empty())
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.ErrorIs(t, err, ErrRequestFailed)
+			reqErr := &RequestError{}
+			assert.ErrorAs(t, err, &reqErr)
+			assert.Equal(t, 51459, reqErr.StationID)
+			assert.Equal(t, 1992, reqErr.Year)
+			assert.Equal(t, 1, reqErr.Month)
+			assert.Equal(t, int(tt.requests), reqErr.Attempts)
+		})
+	}
+
+	t.Run("network error", func(t *testing.T) {
+		s := httptest.NewServer(http.NotFoundHandler())
+		s.Close()
+		_, err := NewClient().BaseURL(s.URL).Retries(1).Backoff(time.Millisecond, time.Millisecond).
+			retreive(context.Background(), 51459, 1992, 1, 1, Daily)
+		assert.ErrorIs(t, err, ErrNetwork)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		c := NewClient().Backoff(10*time.Millisecond, 50*time.Millisecond)
+		for attempt := 1; attempt < 10; attempt++ {
+			d := c.backoff(attempt)
+			assert.Greater(t, int64(d), int64(0))
+			assert.LessOrEqual(t, int64(d), int64(50*time.Millisecond))
+		}
+	})
+}
+
+func TestDownloadFailures(t *testing.T) {
+	// the 1993 hourly datasets are missing
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("Year") == "1993" && (q.Get("Month") == "2" || q.Get("Month") == "7") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `<climatedata><stationdata timetype="LST" day="1" hour="0" minute="0" month="%s" year="%s"><temp>1.0</temp></stationdata></climatedata>`,
+			q.Get("Month"), q.Get("Year"))
+	}))
+	defer s.Close()
+
+	c := NewClient().HTTPClient(s.Client()).BaseURL(s.URL)
+	st := StationMetadata{StationID: 51459, HourlyFirstYear: 1992, HourlyLastYear: 1994}
+	status := st.WithClient(c).RetreiveHourlyData(context.Background())
+
+	errs := 0
+	for done := false; !done; {
+		select {
+		case p := <-status.Progress:
+			if p.Error != nil {
+				assert.ErrorIs(t, p.Error, ErrNotFound)
+				errs++
+			}
+		case <-status.Done:
+			done = true
+		}
+	}
+
+	assert.Equal(t, 2, errs)
+	failed := status.Failed()
+	if assert.Equal(t, 2, len(failed)) {
+		assert.Equal(t, 1993, failed[0].Year)
+		assert.Equal(t, 2, failed[0].Month)
+		assert.Equal(t, 7, failed[1].Month)
+	}
+	assert.Equal(t, 34, len(*st.XML.Data.(*HourlyDataXML)))
+}
+
+func TestResumeDownload(t *testing.T) {
+	requests := new(int32)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		q := r.URL.Query()
+		fmt.Fprintf(w, `<climatedata><stationdata timetype="LST" day="1" hour="0" minute="0" month="%s" year="%s"><temp>1.0</temp></stationdata></climatedata>`,
+			q.Get("Month"), q.Get("Year"))
+	}))
+	defer s.Close()
+
+	// the first 6 months were retreived by a previous download
+	st := StationMetadata{StationID: 51459, HourlyFirstYear: 1992, HourlyLastYear: 1993}
+	previous := HourlyDataXML{}
+	for m := 1; m <= 6; m++ {
+		previous = append(previous, HourlyBaseXML{Year: 1992, Month: m, Day: 1})
+	}
+	st.XML.Data = &previous
+
+	status := st.WithClient(NewClient().HTTPClient(s.Client()).BaseURL(s.URL)).RetreiveHourlyData(context.Background())
+	for done := false; !done; {
+		select {
+		case p := <-status.Progress:
+			assert.Equal(t, 18, p.Total)
+		case <-status.Done:
+			done = true
+		}
+	}
+
+	// the remaining months of 1992 and 1993
+	assert.Equal(t, int32(18), atomic.LoadInt32(requests))
+	assert.Equal(t, 24, len(*st.XML.Data.(*HourlyDataXML)))
+}
@@ -3,14 +3,13 @@ package weather_gc_ca
 import (
 	"context"
 	"encoding/csv"
-	"encoding/xml"
 	"fmt"
 	"io"
 	"math"
-	"net/http"
-	"net/url"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,7 +17,7 @@ func (r RawStations) String() string {
 	a := "Distance\tID\t\tHourly\t\tDaily\t\tMonthly\t\tName\n"
 	for _, s := range r {
 		a += fmt.Sprintf("%.2f\tkm\t%d\t\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
-			s.previousDistance, s.StationID,
+			s.SearchDistance(), s.StationID,
 			s.HourlyFirstYear, s.HourlyLastYear,
 			s.DailyFirstYear, s.DailyLastYear,
 			s.MonthlyFirstYear, s.MonthlyLastYear,
@@ -34,168 +33,158 @@ func (r RawStations) Map(f func(a *StationMetadata)) {
 	}
 }
 
-type distanceMap struct {
-	list     map[float64]StationMetadata
-	farthest float64
+// Find returns the max stations nearest to the coordinates with their SearchDistance,
+// StationInventory is searched with its spatial index, other stations are searched linearly
+func (r RawStations) Find(lat, lng float64, max int) (s RawStations) {
+	return r.nearest(lat, lng, max, nil)
 }
 
-func (m *distanceMap) add(d float64, s StationMetadata, max int) {
-	// fill map with first n[max] stations on list
-	if len(m.list) < max {
-		m.list[d] = s
-		if d > m.farthest {
-			m.farthest = d
-		}
-		return
+// FindWithInterval returns the max stations nearest to the coordinates that have data for the
+// interval with their SearchDistance
+func (r RawStations) FindWithInterval(lat, lng float64, max int, interval Interval) (s RawStations) {
+	return r.nearest(lat, lng, max, func(a *StationMetadata) bool {
+		return a.HasInterval(interval)
+	})
+}
+
+// WithinRadius returns the stations within km of the coordinates sorted by their SearchDistance,
+// StationInventory is searched with its spatial index
+func (r RawStations) WithinRadius(lat, lng, km float64) (s RawStations) {
+	return r.withinRadius(lat, lng, km, nil)
+}
+
+// withinRadius returns the stations within km of the coordinates that are accepted by keep
+func (r RawStations) withinRadius(lat, lng, km float64, keep func(a *StationMetadata) bool) (s RawStations) {
+	if km < 0 {
+		return nil
 	}
 
-	// if this station is closer than the farthest station in the map
-	if d < m.farthest {
-		// remove farthest station from map
-		delete(m.list, m.farthest)
-		// add this station to map
-		m.list[d] = s
-		m.farthest = 0.0
-		// find the new farthest station in the map
-		for distance := range m.list {
-			if distance > m.farthest {
-				m.farthest = distance
+	var candidates []int
+	if idx := r.index(); idx != nil {
+		// the chord is widened slightly so rounding never excludes a station the
+		// distance includes, every candidate is checked with its distance
+		candidates = idx.within(unitVector(lat, lng), chordLimit(km)*(1+1e-9)+1e-12, keep)
+	} else {
+		for i := range r {
+			if keep == nil || keep(&r[i]) {
+				candidates = append(candidates, i)
 			}
 		}
 	}
-}
 
-func (r RawStations) Find(lat, lng float64, max int) (s RawStations) {
-	// get a list of stations sorted by distance
-	m := distanceMap{list: make(map[float64]StationMetadata, max)}
-
-	// TODO: figure out how to start excluding stations early
-	for _, a := range r {
-		d := a.Distance(lat, lng)
-		m.add(d, a, max)
-		// // fill map with first n[max] stations on list
-		// if len(m) < max {
-		// 	m[d] = a
-		// 	if d > farthest {
-		// 		farthest = d
-		// 	}
-		// 	continue
-		// }
-
-		// // if this station is closer than the farthest station in the map
-		// if d < farthest {
-		// 	// remove farthest station from map
-		// 	delete(m, farthest)
-		// 	// add this station to map
-		// 	m[d] = a
-		// 	farthest = 0.0
-		// 	// find the new farthest station in the map
-		// 	for distance := range m {
-		// 		if distance > farthest {
-		// 			farthest = distance
-		// 		}
-		// 	}
-		// }
-	}
-
-	s = nil
-	// convert map to slice
-	for _, v := range m.list {
-		s = append(s, v)
+	for _, i := range candidates {
+		a := r[i]
+		if a.Distance(lat, lng) <= km {
+			s = append(s, a)
+		}
 	}
-
+	s.Sort(SortByDistance)
 	return s
 }
 
-func (r RawStations) FindWithInterval(lat, lng float64, max int, interval Interval) (s RawStations) {
-	// get a list of stations sorted by distance
-	m := &distanceMap{list: make(map[float64]StationMetadata, max)}
+// WithinBBox returns the stations inside the bounding box, in the order of the stations.
+// A box crossing the antimeridian has a minLng greater than its maxLng
+func (r RawStations) WithinBBox(minLat, minLng, maxLat, maxLng float64) (s RawStations) {
 	for _, a := range r {
-		switch interval {
-		case Hourly:
-			if a.HourlyFirstYear == 0 || a.HourlyLastYear == 0 {
-				continue
-			}
-		case Daily:
-			if a.DailyFirstYear == 0 || a.DailyLastYear == 0 {
-				continue
-			}
-		case Monthly:
-			if a.MonthlyFirstYear == 0 || a.MonthlyLastYear == 0 {
-				continue
-			}
-		case Almanac:
-			if a.FirstYear == 0 || a.LastYear == 0 {
-				continue
-			}
+		if a.inBBox(minLat, minLng, maxLat, maxLng) {
+			s = append(s, a)
 		}
-		d := a.Distance(lat, lng)
-		m.add(d, a, max)
-		// fill map with first n[max] stations on list
-		// if len(m) < max {
-		// 	m[d] = a
-		// 	if d > farthest {
-		// 		farthest = d
-		// 	}
-		// 	continue
-		// }
-
-		// // if this station is closer than the farthest station in the map
-		// if d < farthest {
-		// 	// remove farthest station from map
-		// 	delete(m, farthest)
-		// 	// add this station to map
-		// 	m[d] = a
-		// 	farthest = 0.0
-		// 	// find the new farthest station in the map
-		// 	for distance := range m {
-		// 		if distance > farthest {
-		// 			farthest = distance
-		// 		}
-		// 	}
-		// }
-	}
-
-	s = nil
-	// convert map to slice
-	for _, v := range m.list {
-		s = append(s, v)
 	}
-
 	return s
 }
 
-func (r RawStations) NameContains(query string, max int) (s RawStations) {
+func (r *StationMetadata) inBBox(minLat, minLng, maxLat, maxLng float64) bool {
+	if r.Latitude < minLat || r.Latitude > maxLat {
+		return false
+	}
+	if minLng <= maxLng {
+		return r.Longitude >= minLng && r.Longitude <= maxLng
+	}
+	// the box crosses the antimeridian
+	return r.Longitude >= minLng || r.Longitude <= maxLng
+}
+
+// WithInterval returns the stations that have data for the interval, it can be combined
+// with the other searches, eg. WithinRadius(lat, lng, 50).WithInterval(Daily)
+func (r RawStations) WithInterval(interval Interval) (s RawStations) {
 	for _, a := range r {
-		if strings.Contains(strings.ToLower(a.Name), strings.ToLower(query)) {
+		if a.HasInterval(interval) {
 			s = append(s, a)
-			if len(s) >= max {
-				return s
-			}
 		}
 	}
 	return s
 }
 
-func (r RawStations) NameStartsWith(query string, max int) (s RawStations) {
+// WithCoverage returns the stations with data for the interval in at least years of the years
+// from start to end, or in every year if years is 0. Combined with the other searches it finds
+// the stations with continuous data across a study period, eg.
+// WithinRadius(lat, lng, 50).WithCoverage(Daily, 1981, 2010, 0)
+func (r RawStations) WithCoverage(interval Interval, start, end, years int) (s RawStations) {
 	for _, a := range r {
-		if strings.HasPrefix(strings.ToLower(a.Name), strings.ToLower(query)) {
+		if a.HasInterval(interval) && a.coverage(interval, start, end, years) {
 			s = append(s, a)
-			if len(s) >= max {
-				return s
-			}
 		}
 	}
 	return s
 }
 
-func (r RawStations) Station(id int) (s StationMetadata, ok bool) {
-	for _, a := range r {
-		if a.StationID == id {
-			return a, true
+// coverage reports whether the station has data in at least years of the years from start to end,
+// or in every year if years is 0
+func (r *StationMetadata) coverage(interval Interval, start, end, years int) bool {
+	if years <= 0 {
+		return r.Covers(interval, start, end)
+	}
+	return r.CoverageYears(interval, start, end) >= years
+}
+
+// nearest returns the max stations nearest to the coordinates that are accepted by keep
+func (r RawStations) nearest(lat, lng float64, max int, keep func(a *StationMetadata) bool) RawStations {
+	if idx := r.index(); idx != nil {
+		return idx.nearest(lat, lng, max, keep)
+	}
+	return r.findLinear(lat, lng, max, keep)
+}
+
+// index returns the spatial index of the stations, nil if they are not indexed
+func (r RawStations) index() *stationIndex {
+	idx := activeIndex()
+	if idx == nil || len(r) == 0 || len(r) != len(idx.stations) || &r[0] != &idx.stations[0] {
+		return nil
+	}
+	return idx
+}
+
+// findLinear computes the distance to every station
+func (r RawStations) findLinear(lat, lng float64, max int, keep func(a *StationMetadata) bool) (s RawStations) {
+	if max <= 0 {
+		return nil
+	}
+
+	n := &nearestSet{k: max}
+	for i := range r {
+		a := r[i]
+		if keep != nil && !keep(&a) {
+			continue
 		}
+		n.offer(neighbour{station: i, id: a.StationID, key: a.Distance(lat, lng)})
+	}
+
+	for _, a := range n.neighbours() {
+		st := r[a.station]
+		st.previousDistance = a.key
+		s = append(s, st)
 	}
-	return s, false
+	return s
+}
+
+// NameContains returns the first max stations with a name containing the query, ignoring case
+func (r RawStations) NameContains(query string, max int) (s RawStations) {
+	return r.Query(NewStationQuery().NameContains(query).Limit(max))
+}
+
+// NameStartsWith returns the first max stations with a name starting with the query, ignoring case
+func (r RawStations) NameStartsWith(query string, max int) (s RawStations) {
+	return r.Query(NewStationQuery().NameStartsWith(query).Limit(max))
 }
 
 func (r RawStations) csv() (a [][]string) {
@@ -254,6 +243,7 @@ const (
 	SortByHourly
 	SortByDaily
 	SortByMonthly
+	SortByRelevance
 )
 
 func SortByString(a string) SortBy {
@@ -268,6 +258,8 @@ func SortByString(a string) SortBy {
 		return SortByDaily
 	case "monthly":
 		return SortByMonthly
+	case "relevance":
+		return SortByRelevance
 	default:
 		return SortByName
 	}
@@ -277,7 +269,10 @@ func (r RawStations) Sort(by SortBy) {
 	switch by {
 	case SortByDistance:
 		sort.Slice(r, func(i, j int) bool {
-			return r[i].previousDistance < r[j].previousDistance
+			if r[i].SearchDistance() == r[j].SearchDistance() {
+				return r[i].StationID < r[j].StationID
+			}
+			return r[i].SearchDistance() < r[j].SearchDistance()
 		})
 	case SortByName:
 		sort.Slice(r, func(i, j int) bool {
@@ -295,13 +290,27 @@ func (r RawStations) Sort(by SortBy) {
 		sort.Slice(r, func(i, j int) bool {
 			return (r[i].MonthlyLastYear - r[i].MonthlyFirstYear) < (r[j].MonthlyLastYear - r[j].MonthlyFirstYear)
 		})
+	case SortByRelevance:
+		sort.Slice(r, func(i, j int) bool {
+			if r[i].previousScore == r[j].previousScore {
+				if r[i].Name == r[j].Name {
+					return r[i].StationID < r[j].StationID
+				}
+				return r[i].Name < r[j].Name
+			}
+			return r[i].previousScore > r[j].previousScore
+		})
 	}
 }
 
+// Distances returns the stations keyed by the distance of the last search.
+//
+// Deprecated: stations at the same distance overwrite each other, the results of
+// Find and FindWithInterval are sorted by distance and keep every station
 func (r RawStations) Distances() map[float64]StationMetadata {
 	m := make(map[float64]StationMetadata)
 	for _, a := range r {
-		m[a.previousDistance] = a
+		m[a.SearchDistance()] = a
 	}
 	return m
 }
@@ -309,6 +318,9 @@ func (r RawStations) Distances() map[float64]StationMetadata {
 func (r *StationMetadata) String() string {
 	return fmt.Sprintf(
 		`Station ID:	%d
+Climate ID:	%s
+WMO ID:		%s
+TC ID:		%s
 Name:		%s
 Province:	%s
 Latitude:	%.2f
@@ -317,7 +329,7 @@ Elevation:	%.2f m
 Hourly:		%d - %d
 Daily:		%d - %d
 Monthly:	%d - %d
-`, r.StationID, r.Name, r.Province, r.Latitude, r.Longitude, r.Elevation,
+`, r.StationID, r.ClimateID, r.WMOID, r.TCID, r.Name, r.Province, r.Latitude, r.Longitude, r.Elevation,
 		r.HourlyFirstYear, r.HourlyLastYear, r.DailyFirstYear, r.DailyLastYear, r.MonthlyFirstYear, r.MonthlyLastYear)
 
 }
@@ -334,12 +346,59 @@ func (r *StationMetadata) Timeframe(interval Interval) (start int, end int) {
 		return r.DailyFirstYear, r.DailyLastYear
 	case Monthly:
 		return r.MonthlyFirstYear, r.MonthlyLastYear
+	case Almanac:
+		return r.FirstYear, r.LastYear
 	}
 	return 0, 0
 }
 
+// HasInterval reports whether the station has data for the interval, every station
+// is accepted for an unknown interval
+func (r *StationMetadata) HasInterval(interval Interval) bool {
+	switch interval {
+	case Hourly, Daily, Monthly, Almanac:
+		start, end := r.Timeframe(interval)
+		return start != 0 && end != 0
+	}
+	return true
+}
+
+// CoverageYears returns the number of years from start to end in the range of the interval,
+// or in the range of all the data of the station for an unknown interval
+func (r *StationMetadata) CoverageYears(interval Interval, start, end int) int {
+	first, last := r.FirstYear, r.LastYear
+	switch interval {
+	case Hourly, Daily, Monthly, Almanac:
+		first, last = r.Timeframe(interval)
+	}
+	if first == 0 || last == 0 {
+		return 0
+	}
+
+	if first > start {
+		start = first
+	}
+	if last < end {
+		end = last
+	}
+	if end < start {
+		return 0
+	}
+	return end - start + 1
+}
+
+// Covers reports whether the station has data for every year from start to end, see CoverageYears
+func (r *StationMetadata) Covers(interval Interval, start, end int) bool {
+	return end >= start && r.CoverageYears(interval, start, end) == end-start+1
+}
+
+// earthRadius is the mean radius of the earth in km
+const earthRadius = 6371.0
+
+// Distance returns the distance in km from the station to the coordinates, it is kept as
+// the SearchDistance of the station
 func (r *StationMetadata) Distance(lat, lng float64) float64 {
-	rad := 6371.0
+	rad := earthRadius
 	dlat := r.radians(r.Latitude - lat)
 	dlng := r.radians(r.Longitude - lng)
 
@@ -351,56 +410,56 @@ func (r *StationMetadata) Distance(lat, lng float64) float64 {
 	return r.previousDistance
 }
 
+// SearchDistance returns the distance in km from the point of the search that found the
+// station, eg. Find or a StationQuery with Near, 0 if it was not searched by distance
+func (r StationMetadata) SearchDistance() float64 {
+	return r.previousDistance
+}
+
 func (r StationMetadata) radians(deg float64) float64 {
 	return deg * math.Pi / 180
 }
 
-// https://climate.weather.gc.ca/climate_data/bulk_data_e.html
-//				?format=xml&stationID=5097&Year=${year}&Month=${month}&Day=1&timeframe=2&submit= Download+Data
-func (r *StationMetadata) RetreiveData(year, month, day int, interval Interval) error {
-	q := url.Values{}
-	q.Add("format", "xml")
-	q.Add("stationID", fmt.Sprintf("%d", r.StationID))
-	q.Add("Year", fmt.Sprintf("%d", year))
-	q.Add("Month", fmt.Sprintf("%d", month))
-	q.Add("Day", fmt.Sprintf("%d", day))
-	q.Add("timeframe", fmt.Sprintf("%d", interval))
-	q.Add("submit", " Download+Data")
-	u := url.URL{
-		Scheme:   "https",
-		Host:     "climate.weather.gc.ca",
-		Path:     "/climate_data/bulk_data_e.html",
-		RawQuery: q.Encode(),
-	}
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return fmt.Errorf("failed to retreive dataset: %s", err)
-	}
+// WithClient sets the client used by the Retreive* methods of the station,
+// DefaultClient is used if none is set
+func (r *StationMetadata) WithClient(c *Client) *StationMetadata {
+	r.client = c
+	return r
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to retreive dataset: %s", err)
+func (r *StationMetadata) httpClient() *Client {
+	if r.client == nil {
+		return DefaultClient
 	}
+	return r.client
+}
 
-	x := ClimateDataXML{}
-	switch interval {
-	case Hourly:
-		x.Data = &HourlyDataXML{}
-	case Daily:
-		x.Data = &DailyDataXML{}
-	case Monthly:
-		x.Data = &MonthlyDataXML{}
-	}
-	err = xml.NewDecoder(resp.Body).Decode(&x)
+func (r *StationMetadata) RetreiveData(year, month, day int, interval Interval) error {
+	data, err := r.retreive(context.Background(), year, month, day, interval)
 	if err != nil {
-		return fmt.Errorf("failed to retreive dataset: %s", err)
+		return err
 	}
 
-	r.XML.Data.Append(x.Data)
+	r.XML.Data.Append(data)
 
 	return nil
 }
 
+// retreive downloads and decodes a single dataset without appending it to r.XML.Data
+func (r *StationMetadata) retreive(ctx context.Context, year, month, day int, interval Interval) (StationDataXML, error) {
+	data, err := r.httpClient().retreive(ctx, r.StationID, year, month, day, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// the zone is set from the station information of the dataset, fall back
+	// to the inventory if the dataset did not include it
+	if h, ok := data.(*HourlyDataXML); ok && !h.Empty() && (*h)[0].Time.IsZero() {
+		h.SetLocation(r.Location())
+	}
+	return data, nil
+}
+
 func (r *StationMetadata) RetreiveHourlyData(ctx context.Context) DownloadStatus {
 	start, end := Timeframe{
 		Day:   1,
@@ -441,6 +500,19 @@ func (r *StationMetadata) RetreiveMonthlyData(ctx context.Context) DownloadStatu
 	return r.retreiveBetween(ctx, start, end, Monthly)
 }
 
+func (r *StationMetadata) RetreiveAlmanacData(ctx context.Context) DownloadStatus {
+	start, end := Timeframe{
+		Day:   1,
+		Month: 1,
+		Year:  r.FirstYear,
+	}, Timeframe{
+		Day:   31,
+		Month: 12,
+		Year:  r.LastYear,
+	}
+	return r.retreiveBetween(ctx, start, end, Almanac)
+}
+
 func (r *StationMetadata) RetreiveInterval(ctx context.Context, interval Interval) DownloadStatus {
 	start, end := r.Timeframe(interval)
 	return r.retreiveBetween(ctx,
@@ -450,6 +522,7 @@ func (r *StationMetadata) RetreiveInterval(ctx context.Context, interval Interva
 	)
 }
 
+// RetreiveTimeframe downloads the data of the interval from the start year to the end year, inclusive
 func (r *StationMetadata) RetreiveTimeframe(ctx context.Context, start, end Timeframe, interval Interval) DownloadStatus {
 	return r.retreiveBetween(ctx, start, end, interval)
 }
@@ -459,79 +532,171 @@ func (r *StationMetadata) retreiveBetween(ctx context.Context, start, end Timefr
 
 	switch interval {
 	case Hourly:
-		// yr = r.HourlyFirstYear
-		// eyr = r.HourlyLastYear
-		r.XML.Data = &HourlyDataXML{}
 	case Daily:
-		// yr = r.DailyFirstYear
-		// eyr = r.DailyLastYear
 		emon = 1
-		r.XML.Data = &DailyDataXML{}
-	case Monthly:
-		// yr = r.MonthlyFirstYear
-		// eyr = r.MonthlyFirstYear // first year because all data comes in 1 file
+	case Monthly, Almanac:
+		// the monthly data and the almanac are a single dataset of the whole period
+		// of record, whatever the year requested
+		eyr = yr
 		emon = 1
-		r.XML.Data = &MonthlyDataXML{}
 	default:
 		return DownloadStatus{}
 	}
 
-	total := (eyr - yr) * (emon - mon + 1)
+	// keep the data of a previous download of the same interval so the
+	// periods that were already retreived are skipped
+	data := NewStationData(interval)
+	if r.XML.Data == nil || reflect.TypeOf(r.XML.Data) != reflect.TypeOf(data) {
+		r.XML.Data = data
+	}
+
+	// the end year is included
+	periods := []period{}
+	for ; yr <= eyr; yr++ {
+		for ; mon <= emon; mon++ {
+			// try to find the data in the existing data
+			// if hourly, we assume the entire month exists if the first entry exists
+			// if daily, we assume the entire year exists if the first entry exists
+			// if monthly/almanac, the period of record exists if there is any data
+			if (interval == Monthly || interval == Almanac) && !r.XML.Data.Empty() {
+				continue
+			}
+			if _, ok := r.XML.Data.Find(Timeframe{
+				Year:  yr,
+				Month: mon,
+				Day:   1,
+				Time:  time.Date(yr, time.Month(mon), 1, 0, 0, 0, 0, time.UTC),
+			}); ok {
+				continue
+			}
+			periods = append(periods, period{index: len(periods), year: yr, month: mon})
+		}
+		mon = 1
+	}
 
 	d := DownloadStatus{
 		Progress: make(chan DownloadProgress),
-		Done:     make(chan bool),
+		Done:     make(chan bool, 1),
+		failed:   &failedPeriods{},
 	}
 
+	go r.download(ctx, d, periods, interval)
+
+	return d
+}
+
+// period is a single dataset request of a download
+type period struct {
+	index int
+	year  int
+	month int
+}
+
+type periodResult struct {
+	period
+	data StationDataXML
+	err  error
+}
+
+// download retreives the periods using a pool of workers sized by the client concurrency,
+// the results are appended to r.XML.Data and reported in chronological order
+func (r *StationMetadata) download(ctx context.Context, d DownloadStatus, periods []period, interval Interval) {
+	client := r.httpClient()
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan period)
 	go func() {
-		// iterate each year, month
-		count := 1
-		for ; yr < eyr; yr++ {
-		M:
-			for ; mon <= emon; mon++ {
-				// try to find the data in the existing data
-				// if hourly, we assume the entire month exists if the first entry exists
-				// if daily/monthly, we assume the entire year exists if the first entry exists
-				if _, ok := r.XML.Data.Find(Timeframe{
-					Year:  yr,
-					Month: mon,
-					Day:   1,
-					Time:  time.Date(yr, time.Month(mon), 1, 0, 0, 0, 0, time.UTC),
-				}); ok {
-					continue M
-				}
+		defer close(jobs)
+		for _, p := range periods {
+			select {
+			case jobs <- p:
+			case <-workerCtx.Done():
+				return
+			}
+		}
+	}()
 
-				// download the data
+	results := make(chan periodResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < client.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				data, err := r.retreive(workerCtx, p.year, p.month, 1, interval)
 				select {
-				case <-ctx.Done():
-					d.Done <- false
+				case results <- periodResult{period: p, data: data, err: err}:
+				case <-workerCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// results arrive in any order, hold them until every earlier period has been reported
+	pending := map[int]periodResult{}
+	next := 0
+	for res := range results {
+		pending[res.index] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if ctx.Err() != nil {
+				d.Done <- false
+				return
+			}
+
+			progress := DownloadProgress{
+				Total: len(periods),
+				Count: next,
+			}
+
+			if res.err != nil {
+				d.failed.add(res.err)
+				progress.Error = res.err
+				if !d.send(ctx, progress) {
 					return
-				default:
-					progress := DownloadProgress{
-						Total: total,
-						Count: count,
-					}
-
-					err := r.RetreiveData(yr, mon, 1, interval)
-					if err != nil {
-						fmt.Println(err)
-						progress.Error = ErrRequestFailed
-						d.Progress <- progress
-						continue M
-					}
-
-					progress.Timeframe = r.XML.Data.Last().Timeframe()
-					progress.Time = time.Now().Unix()
-					d.Progress <- progress
-
-					count++
 				}
+				continue
+			}
+			r.XML.Data.Append(res.data)
+
+			progress.Data = res.data
+			if last := r.XML.Data.Last(); last != nil {
+				progress.Timeframe = last.Timeframe()
+			}
+			progress.Time = time.Now().Unix()
+			if !d.send(ctx, progress) {
+				return
 			}
-			mon = 1
 		}
+	}
 
-		d.Done <- true
-	}()
+	if ctx.Err() != nil {
+		d.Done <- false
+		return
+	}
+	d.Done <- true
+}
 
-	return d
+// send delivers the progress to the receiver, if the context is cancelled before
+// the progress is received Done is sent false and send returns false
+func (d DownloadStatus) send(ctx context.Context, p DownloadProgress) bool {
+	select {
+	case d.Progress <- p:
+		return true
+	case <-ctx.Done():
+		d.Done <- false
+		return false
+	}
 }
@@ -77,6 +77,42 @@ func TestXML(t *testing.T) {
 
 		assert.Greater(t, len(*d), 0)
 	})
+
+	t.Run("test almanac", func(t *testing.T) {
+		b, err := ioutil.ReadFile("./_testdata/test-almanac_toronto.xml")
+		if err != nil {
+			t.Error(err)
+		}
+		d := &AlmanacDataXML{}
+		x := &ClimateDataXML{Data: d}
+		err = xml.Unmarshal(b, x)
+		if err != nil {
+			t.Error(err)
+		}
+
+		assert.Equal(t, "TORONTO LESTER B. PEARSON INT'L A", x.StationInfo.Name)
+		assert.Equal(t, "6158733", x.StationInfo.ClimateID)
+
+		assert.Equal(t, 366, len(*d))
+		first := (*d)[0]
+		assert.Equal(t, 1, first.Month)
+		assert.Equal(t, 1, first.Day)
+		assert.Equal(t, 12.0, first.ExtremeMaxTemp.Value)
+		assert.Equal(t, 2011, first.ExtremeMaxTemp.Year)
+		assert.Equal(t, "1939-2013", first.ExtremeMaxTemp.Period)
+		assert.Equal(t, -21.1, first.ExtremeMinTemp.Value)
+		assert.Equal(t, -5.3, first.NormalMeanTemp.Value)
+		assert.Equal(t, 17.8, first.ExtremeSnowfall.Value)
+		assert.Equal(t, 18.0, first.ExtremeSnowOnGround.Value)
+		assert.Equal(t, 49.0, first.POP)
+
+		_, ok := d.Find(Timeframe{Month: 2, Day: 29})
+		assert.True(t, ok, "expected almanac record for February 29")
+
+		rows := d.csv()
+		assert.Equal(t, 367, len(rows))
+		assert.Equal(t, len(rows[0]), len(rows[1]))
+	})
 }
 
 func TestMethods(t *testing.T) {
@@ -93,7 +129,59 @@ func TestMethods(t *testing.T) {
 
 			assert.LessOrEqualf(t, v.Longitude, lng+adv, "longitude for station %d is %.2f , expected <= %.2f", v.StationID, v.Longitude, lng)
 			assert.GreaterOrEqualf(t, v.Longitude, lng-adv, "longitude for station %d is %.2f , expected >= %.2f", v.StationID, v.Longitude, lng)
+
+			a := v
+			assert.InDelta(t, a.Distance(lat, lng), v.SearchDistance(), 1e-9)
+		}
+
+		// the stations of the inventory are not searched
+		assert.Zero(t, StationInventory[0].SearchDistance())
+	})
+
+	t.Run("Test WithinRadius", func(t *testing.T) {
+		lat, lng, km := 50.4452, -104.6189, 100.0
+		s := StationInventory.WithinRadius(lat, lng, km)
+		assert.NotEmpty(t, s)
+
+		count := 0
+		for _, v := range StationInventory {
+			if v.Distance(lat, lng) <= km {
+				count++
+			}
+		}
+		assert.Equal(t, count, len(s))
+
+		for i, v := range s {
+			assert.LessOrEqual(t, v.SearchDistance(), km)
+			if i > 0 {
+				assert.LessOrEqual(t, s[i-1].SearchDistance(), v.SearchDistance())
+			}
+		}
+
+		// the linear search of other stations agrees with the index
+		assert.Equal(t, s, append(RawStations{}, StationInventory...).WithinRadius(lat, lng, km))
+		assert.Empty(t, StationInventory.WithinRadius(lat, lng, -1))
+
+		for _, v := range s.WithInterval(Hourly) {
+			assert.NotZero(t, v.HourlyFirstYear)
+		}
+	})
+
+	t.Run("Test WithinBBox", func(t *testing.T) {
+		s := StationInventory.WithinBBox(50, -105, 51, -104)
+		assert.NotEmpty(t, s)
+		for _, v := range s {
+			assert.True(t, v.Latitude >= 50 && v.Latitude <= 51 && v.Longitude >= -105 && v.Longitude <= -104)
+		}
+
+		r := RawStations{
+			{StationID: 1, Latitude: 0, Longitude: 179.5},
+			{StationID: 2, Latitude: 0, Longitude: -179.5},
+			{StationID: 3, Latitude: 0, Longitude: 0},
 		}
+		assert.Len(t, r.WithinBBox(-1, 179, 1, -179), 2)
+		assert.Len(t, r.WithinBBox(-1, -1, 1, 1), 1)
+		assert.Empty(t, r.WithinBBox(1, -180, 2, 180))
 	})
 
 	t.Run("Test Get", func(t *testing.T) {
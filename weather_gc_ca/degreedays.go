@@ -0,0 +1,345 @@
+package weather_gc_ca
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DegreeDayMethod is the method used to compute the degree days of a day from its
+// maximum and minimum temperatures
+type DegreeDayMethod int
+
+const (
+	// Average compares the average of the maximum and minimum temperatures to the base,
+	// as the HeatDegDays and CoolDegDays published by ECCC
+	Average DegreeDayMethod = 1
+	// SingleSine fits a sine curve through the minimum and maximum temperatures of the day
+	// and integrates the degrees above the base and below the cap (Baskerville-Emin)
+	SingleSine DegreeDayMethod = 2
+)
+
+func (m DegreeDayMethod) String() string {
+	switch m {
+	case Average:
+		return "average"
+	case SingleSine:
+		return "sine"
+	}
+	return "unknown"
+}
+
+// DegreeDayMethodString parses a method from its name, returning 0 if unknown
+func DegreeDayMethodString(a string) DegreeDayMethod {
+	switch strings.ToLower(a) {
+	case "average", "avg", "simple":
+		return Average
+	case "sine", "single-sine", "singlesine":
+		return SingleSine
+	}
+	return 0
+}
+
+// DegreeDays computes the degree days of daily or hourly data, the degrees above the base
+// for growing and cooling degree days or the degrees below it for heating degree days. The
+// temperatures above the cap are counted as the cap, eg. the 30 °C cap of the corn heat units,
+// the cap is not used for heating degree days
+type DegreeDays struct {
+	base     float64
+	cap      float64
+	method   DegreeDayMethod
+	heating  bool
+	complete float64
+}
+
+// NewDegreeDays returns the growing degree days above the base, without a cap, using the
+// Average method. Hourly data needs the DefaultDailyCompleteness of the hours of a day
+func NewDegreeDays(base float64) *DegreeDays {
+	return &DegreeDays{
+		base:     base,
+		cap:      math.Inf(1),
+		method:   Average,
+		complete: DefaultDailyCompleteness,
+	}
+}
+
+// Cap sets the upper temperature, the temperatures above it are counted as the cap
+func (dd *DegreeDays) Cap(upper float64) *DegreeDays {
+	dd.cap = upper
+	return dd
+}
+
+// Method sets the method used for daily data, hourly data is always integrated hour by hour
+func (dd *DegreeDays) Method(method DegreeDayMethod) *DegreeDays {
+	dd.method = method
+	return dd
+}
+
+// Heating counts the degrees below the base instead of above it
+func (dd *DegreeDays) Heating() *DegreeDays {
+	dd.heating = true
+	return dd
+}
+
+// DailyCompleteness sets the share, from 0 to 1, of the hours of a day needed for the degree days of hourly data
+func (dd *DegreeDays) DailyCompleteness(share float64) *DegreeDays {
+	dd.complete = share
+	return dd
+}
+
+// DegreeDay is the degree days of a day and the degree days accumulated since the first day
+type DegreeDay struct {
+	Year        int         `json:"year"`
+	Month       int         `json:"month"`
+	Day         int         `json:"day"`
+	DegreeDays  Measurement `json:"degreeDays"`
+	Accumulated Measurement `json:"accumulated"`
+}
+
+func (a DegreeDay) MarshalJSON() ([]byte, error) {
+	return marshalRecord(a)
+}
+
+func (a *DegreeDay) UnmarshalJSON(b []byte) error {
+	return unmarshalRecord(b, a)
+}
+
+func (a DegreeDay) Timeframe() Timeframe {
+	return Timeframe{
+		Year:  a.Year,
+		Month: a.Month,
+		Day:   a.Day,
+		Time:  time.Date(a.Year, time.Month(a.Month), a.Day, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// DegreeDayData is the degree days of consecutive days in chronological order
+type DegreeDayData []DegreeDay
+
+// Compute returns the degree days of daily or hourly data
+func (dd *DegreeDays) Compute(data StationDataXML) (DegreeDayData, error) {
+	switch d := data.(type) {
+	case *DailyDataXML:
+		return dd.Daily(d), nil
+	case *HourlyDataXML:
+		return dd.Hourly(d), nil
+	}
+	return nil, fmt.Errorf("failed to compute degree days: cannot compute degree days of %T", data)
+}
+
+// Daily returns the degree days of each day of the daily data from its maximum and minimum
+// temperatures, a day without them has no data. The Average method uses the published mean
+// temperature of the days below the cap, so the heating and cooling degree days at 18 °C are
+// the HeatDegDays and CoolDegDays of the data. An unknown method is the Average method
+func (dd *DegreeDays) Daily(d *DailyDataXML) DegreeDayData {
+	s := DegreeDayData{}
+	for _, a := range *d {
+		day := DegreeDay{Year: a.Year, Month: a.Month, Day: a.Day}
+		switch {
+		case dd.method != SingleSine && a.MeanTemp.Valid && (dd.heating || a.MaxTemp.Valid && a.MaxTemp.Value <= dd.cap):
+			// the published mean is computed from the unrounded extremes
+			day.DegreeDays = NewMeasurement(round1(dd.degrees(a.MeanTemp.Value)))
+			day.DegreeDays.Flag = a.MeanTemp.Flag
+		case a.MaxTemp.Valid && a.MinTemp.Valid:
+			day.DegreeDays = NewMeasurement(round1(dd.day(a.MinTemp.Value, a.MaxTemp.Value)))
+			if a.MaxTemp.Flag != nil {
+				day.DegreeDays.Flag = a.MaxTemp.Flag
+			} else {
+				day.DegreeDays.Flag = a.MinTemp.Flag
+			}
+		}
+		s = append(s, day)
+	}
+	return s.sorted()
+}
+
+// Hourly returns the degree days of each day of the hourly data, the average over the hours of
+// the day of the degrees above the base, or below it. The days are on the wall clock of the
+// records as in Resampler.Daily
+func (dd *DegreeDays) Hourly(h *HourlyDataXML) DegreeDayData {
+	days := map[Timeframe]aggregate{}
+	periods := []Timeframe{}
+	for _, a := range *h {
+		t := Timeframe{Year: a.Year, Month: a.Month, Day: a.Day}
+		if _, ok := days[t]; !ok {
+			periods = append(periods, t)
+			days[t] = aggregate{}
+		}
+		if a.Temp.Valid {
+			days[t] = append(days[t], dd.degrees(a.Temp.Value))
+		}
+	}
+	sortPeriods(periods)
+
+	s := DegreeDayData{}
+	p := completeness{expected: 24, share: dd.complete}
+	for _, t := range periods {
+		s = append(s, DegreeDay{
+			Year:       t.Year,
+			Month:      t.Month,
+			Day:        t.Day,
+			DegreeDays: p.measurement(days[t], aggregate.mean),
+		})
+	}
+	return s.accumulate()
+}
+
+// degrees returns the degrees of a temperature above the base and below the cap, or below the base
+func (dd *DegreeDays) degrees(temp float64) float64 {
+	if dd.heating {
+		return math.Max(0, dd.base-temp)
+	}
+	return math.Max(0, math.Min(temp, dd.cap)-dd.base)
+}
+
+// day returns the degree days of a day with the minimum and maximum temperatures
+func (dd *DegreeDays) day(min, max float64) float64 {
+	if min > max {
+		min, max = max, min
+	}
+
+	if dd.method == SingleSine {
+		if dd.heating {
+			// the degrees below the base are the degrees above it less the difference of the
+			// mean to the base, as the sine averages to the mean over the day
+			return dd.base - (min+max)/2 + singleSine(min, max, dd.base, math.Inf(1))
+		}
+		return singleSine(min, max, dd.base, dd.cap)
+	}
+
+	if !dd.heating {
+		min, max = math.Min(min, dd.cap), math.Min(max, dd.cap)
+	}
+	// the mean is rounded as the MeanTemp published by ECCC
+	return dd.degrees(round1((min + max) / 2))
+}
+
+// singleSine returns the integral over a day of a sine curve from min to max above the lower
+// threshold, with a horizontal cutoff at the upper threshold
+func singleSine(min, max, lower, upper float64) float64 {
+	switch {
+	case max <= lower:
+		return 0
+	case min >= upper:
+		return upper - lower
+	}
+
+	mean, amplitude := (max+min)/2, (max-min)/2
+	switch {
+	case min >= lower && max <= upper:
+		return mean - lower
+	case min < lower && max <= upper:
+		t1 := math.Asin((lower - mean) / amplitude)
+		return ((mean-lower)*(math.Pi/2-t1) + amplitude*math.Cos(t1)) / math.Pi
+	case min >= lower:
+		t2 := math.Asin((upper - mean) / amplitude)
+		return ((mean-lower)*(t2+math.Pi/2) + (upper-lower)*(math.Pi/2-t2) - amplitude*math.Cos(t2)) / math.Pi
+	}
+	t1 := math.Asin((lower - mean) / amplitude)
+	t2 := math.Asin((upper - mean) / amplitude)
+	return ((mean-lower)*(t2-t1) + amplitude*(math.Cos(t1)-math.Cos(t2)) + (upper-lower)*(math.Pi/2-t2)) / math.Pi
+}
+
+// sorted sorts the days in chronological order and accumulates them
+func (s DegreeDayData) sorted() DegreeDayData {
+	sort.SliceStable(s, func(i, j int) bool {
+		return s[i].Timeframe().Time.Before(s[j].Timeframe().Time)
+	})
+	return s.accumulate()
+}
+
+// accumulate sets the degree days accumulated from the first day, flagged FlagIncomplete
+// from the first day without data or missing between two days
+func (s DegreeDayData) accumulate() DegreeDayData {
+	total, missing := 0.0, false
+	for i := range s {
+		a := &s[i]
+		if i > 0 && !a.Timeframe().Time.Equal(s[i-1].Timeframe().Time.AddDate(0, 0, 1)) {
+			missing = true
+		}
+		if a.DegreeDays.Valid {
+			total += a.DegreeDays.Value
+		} else {
+			missing = true
+		}
+
+		a.Accumulated = NewMeasurement(round1(total))
+		if missing {
+			a.Accumulated.Flag = lookupFlag(FlagIncomplete)
+		}
+	}
+	return s
+}
+
+// Between returns the days from start to end, inclusive, accumulated from start. The dates
+// are compared as days, the zone and time of day are ignored
+func (s DegreeDayData) Between(start, end time.Time) DegreeDayData {
+	start, end = date(start), date(end)
+
+	b := DegreeDayData{}
+	for _, a := range s {
+		t := a.Timeframe().Time
+		if !t.Before(start) && !t.After(end) {
+			b = append(b, a)
+		}
+	}
+	b.accumulate()
+
+	// the days missing before the first day of the data are missing from the accumulation
+	if len(b) > 0 && !b[0].Timeframe().Time.Equal(start) {
+		for i := range b {
+			b[i].Accumulated.Flag = lookupFlag(FlagIncomplete)
+		}
+	}
+	return b
+}
+
+// Total returns the degree days accumulated from start to end, inclusive, flagged FlagIncomplete
+// when any day of the range has no data. A range without data has no data
+func (s DegreeDayData) Total(start, end time.Time) Measurement {
+	b := s.Between(start, end)
+	if len(b) == 0 {
+		return Measurement{}
+	}
+
+	last := b[len(b)-1]
+	if !last.Timeframe().Time.Equal(date(end)) {
+		last.Accumulated.Flag = lookupFlag(FlagIncomplete)
+	}
+	return last.Accumulated
+}
+
+// date returns the day of the time as the Time of a Timeframe
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (s DegreeDayData) csv() [][]string {
+	a := [][]string{{
+		"Year",
+		"Month",
+		"Day",
+		"DegreeDays", "DegreeDaysFlag",
+		"Accumulated", "AccumulatedFlag",
+	}}
+	for _, d := range s {
+		row := []string{
+			fmt.Sprintf("%d", d.Year),
+			fmt.Sprintf("%d", d.Month),
+			fmt.Sprintf("%d", d.Day),
+		}
+		row = append(row, d.DegreeDays.csv()...)
+		row = append(row, d.Accumulated.csv()...)
+		a = append(a, row)
+	}
+	return a
+}
+
+// CSV writes the degree days, a row for each day
+func (s DegreeDayData) CSV(w io.Writer) error {
+	return csv.NewWriter(w).WriteAll(s.csv())
+}
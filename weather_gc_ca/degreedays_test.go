@@ -0,0 +1,145 @@
+package weather_gc_ca
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDegreeDays(t *testing.T) {
+	d := &DailyDataXML{}
+	decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+	day := func(month, day int) time.Time {
+		return time.Date(1992, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("published", func(t *testing.T) {
+		// the average at 18 °C is the heating and cooling degree days published by ECCC
+		heating := NewDegreeDays(18).Heating().Daily(d)
+		cooling := NewDegreeDays(18).Daily(d)
+		if !assert.Len(t, heating, len(*d)) {
+			return
+		}
+		for i, a := range *d {
+			assert.Equal(t, a.HeatDegDays, heating[i].DegreeDays, "%s heating", a.Timeframe())
+			assert.Equal(t, a.CoolDegDays, cooling[i].DegreeDays, "%s cooling", a.Timeframe())
+		}
+	})
+
+	t.Run("methods", func(t *testing.T) {
+		dd := NewDegreeDays(10).Cap(30)
+		assert.InDelta(t, 0, dd.day(-5, 8), 1e-9)
+		assert.InDelta(t, 5, dd.day(12, 18), 1e-9)
+		// the maximum is counted as the cap
+		assert.InDelta(t, 12.5, dd.day(15, 35), 1e-9)
+		assert.InDelta(t, 20, dd.day(31, 35), 1e-9)
+
+		dd.Method(SingleSine)
+		assert.InDelta(t, 0, dd.day(-5, 8), 1e-9)
+		assert.InDelta(t, 5, dd.day(12, 18), 1e-9)
+		assert.InDelta(t, 20, dd.day(31, 35), 1e-9)
+		// half of a sine symmetric around the base
+		assert.InDelta(t, 10/math.Pi, dd.day(0, 20), 1e-9)
+		// the sine counts the warm hours of a day with a mean below the base
+		assert.True(t, dd.day(2, 16) > 0)
+		// only the hours above the cap are cut, not the whole maximum
+		assert.True(t, dd.day(15, 35) > 12.5 && dd.day(15, 35) < 15)
+
+		heating := NewDegreeDays(18).Heating().Method(SingleSine)
+		assert.InDelta(t, 10, heating.day(4, 12), 1e-9)
+		assert.InDelta(t, 10/math.Pi, heating.day(8, 28), 1e-9)
+	})
+
+	t.Run("accumulate", func(t *testing.T) {
+		gdd := NewDegreeDays(5).Cap(30).Daily(d)
+		season := gdd.Between(day(4, 1), day(10, 31))
+		if !assert.Len(t, season, 214) {
+			return
+		}
+		assert.Equal(t, 1992, season[0].Year)
+		assert.Equal(t, 4, season[0].Month)
+		assert.Equal(t, season[0].DegreeDays, season[0].Accumulated)
+
+		total := gdd.Total(day(4, 1), day(10, 31))
+		assert.Equal(t, season[len(season)-1].Accumulated, total)
+		assert.Nil(t, total.Flag)
+		sum := 0.0
+		for _, a := range season {
+			sum += a.DegreeDays.Value
+		}
+		assert.InDelta(t, sum, total.Value, 0.05)
+
+		// the range is compared by day, the time of day is ignored
+		assert.Equal(t, total, gdd.Total(day(4, 1).Add(13*time.Hour), day(10, 31).Add(time.Hour)))
+
+		// 5 days of december are missing
+		assert.True(t, gdd.Total(day(12, 1), day(12, 31)).Flagged(FlagIncomplete))
+		// the range extends past the data
+		assert.True(t, gdd.Total(day(10, 1), day(10, 31).AddDate(1, 0, 0)).Flagged(FlagIncomplete))
+		assert.True(t, gdd.Total(day(1, 1).AddDate(-1, 0, 0), day(1, 31)).Flagged(FlagIncomplete))
+		assert.False(t, gdd.Total(day(1, 1).AddDate(-1, 0, 0), day(1, 1).AddDate(0, 0, -1)).Valid)
+
+		// a day missing between two days
+		gap := append(DailyDataXML{}, (*d)[:10]...)
+		gap = append(gap, (*d)[11:31]...)
+		g := NewDegreeDays(-10).Daily(&gap)
+		assert.Nil(t, g[9].Accumulated.Flag)
+		assert.True(t, g[10].Accumulated.Flagged(FlagIncomplete))
+	})
+
+	t.Run("hourly", func(t *testing.T) {
+		h := &HourlyDataXML{}
+		decodeTestData(t, "./_testdata/test-hourly_toronto.xml", h)
+
+		heating, err := NewDegreeDays(18).Heating().Compute(h)
+		if !assert.NoError(t, err) || !assert.Len(t, heating, 31) {
+			return
+		}
+		// the hourly integration is close to the average of the published data
+		published := NewDegreeDays(18).Heating().Daily(d).Total(day(1, 1), day(1, 31))
+		assert.InDelta(t, published.Value, heating.Total(day(1, 1), day(1, 31)).Value, 0.02*published.Value)
+
+		// too few hours of a day
+		partial := append(HourlyDataXML{}, (*h)[:17]...)
+		dd := NewDegreeDays(18).Heating()
+		assert.False(t, dd.Hourly(&partial)[0].DegreeDays.Valid)
+		assert.True(t, dd.DailyCompleteness(0.5).Hourly(&partial)[0].DegreeDays.Flagged(FlagIncomplete))
+
+		_, err = dd.Compute(&MonthlyDataXML{})
+		assert.Error(t, err)
+	})
+
+	t.Run("writers", func(t *testing.T) {
+		gdd := NewDegreeDays(5).Daily(d).Between(day(12, 1), day(12, 31))
+
+		b := &bytes.Buffer{}
+		if assert.NoError(t, gdd.CSV(b)) {
+			rows, err := csv.NewReader(b).ReadAll()
+			if assert.NoError(t, err) && assert.Len(t, rows, 32) {
+				assert.Equal(t, []string{"Year", "Month", "Day", "DegreeDays", "DegreeDaysFlag", "Accumulated", "AccumulatedFlag"}, rows[0])
+				assert.Equal(t, []string{"1992", "12", "11", "", "", rows[10][5], "^"}, rows[11])
+			}
+		}
+
+		j, err := json.Marshal(gdd)
+		if !assert.NoError(t, err) {
+			return
+		}
+		decoded := DegreeDayData{}
+		if assert.NoError(t, json.Unmarshal(j, &decoded)) {
+			assert.Equal(t, gdd, decoded)
+		}
+	})
+
+	t.Run("method string", func(t *testing.T) {
+		assert.Equal(t, Average, DegreeDayMethodString("average"))
+		assert.Equal(t, SingleSine, DegreeDayMethodString("Sine"))
+		assert.Equal(t, DegreeDayMethod(0), DegreeDayMethodString("triangle"))
+		assert.Equal(t, "sine", SingleSine.String())
+	})
+}
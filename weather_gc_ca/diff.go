@@ -0,0 +1,237 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of change of a station between two inventories
+const (
+	ChangeAdded    = "added"    // the station is only in the new inventory
+	ChangeRemoved  = "removed"  // the station is only in the old inventory
+	ChangeClosed   = "closed"   // the station was current in the old inventory and its data stopped
+	ChangeMoved    = "moved"    // the coordinates of the station changed
+	ChangeExtended = "extended" // the years of data of the station grew
+	ChangeModified = "modified" // any other field of the station changed
+)
+
+// FieldChange is a field of a station that changed, the field is named as in the inventory CSV
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// StationChange is a station that changed between two inventories
+type StationChange struct {
+	StationID int           `json:"stationID"`
+	Name      string        `json:"name"`
+	Kinds     []string      `json:"kinds"`
+	Fields    []FieldChange `json:"fields,omitempty"`
+	Moved     float64       `json:"moved,omitempty"` // km between the old and new coordinates
+}
+
+// Is reports whether the change is of the kind
+func (c StationChange) Is(kind string) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// InventoryDiff is the changes between two inventories, sorted by StationID
+type InventoryDiff []StationChange
+
+// inventoryField is a field compared by DiffInventory
+type inventoryField struct {
+	name  string
+	value func(a *StationMetadata) interface{}
+}
+
+var inventoryFields = []inventoryField{
+	{"Name", func(a *StationMetadata) interface{} { return a.Name }},
+	{"Province", func(a *StationMetadata) interface{} { return a.Province }},
+	{"Climate ID", func(a *StationMetadata) interface{} { return a.ClimateID }},
+	{"WMO ID", func(a *StationMetadata) interface{} { return a.WMOID }},
+	{"TC ID", func(a *StationMetadata) interface{} { return a.TCID }},
+	{"Latitude (Decimal Degrees)", func(a *StationMetadata) interface{} { return a.Latitude }},
+	{"Longitude (Decimal Degrees)", func(a *StationMetadata) interface{} { return a.Longitude }},
+	{"Elevation (m)", func(a *StationMetadata) interface{} { return a.Elevation }},
+	{"First Year", func(a *StationMetadata) interface{} { return a.FirstYear }},
+	{"Last Year", func(a *StationMetadata) interface{} { return a.LastYear }},
+	{"HLY First Year", func(a *StationMetadata) interface{} { return a.HourlyFirstYear }},
+	{"HLY Last Year", func(a *StationMetadata) interface{} { return a.HourlyLastYear }},
+	{"DLY First Year", func(a *StationMetadata) interface{} { return a.DailyFirstYear }},
+	{"DLY Last Year", func(a *StationMetadata) interface{} { return a.DailyLastYear }},
+	{"MLY First Year", func(a *StationMetadata) interface{} { return a.MonthlyFirstYear }},
+	{"MLY Last Year", func(a *StationMetadata) interface{} { return a.MonthlyLastYear }},
+}
+
+// DiffInventory returns the stations added, removed or changed from the previous inventory to
+// the current one, matched by StationID. A changed station is moved when its coordinates changed,
+// extended when any of its ranges of years grew and closed when its Last Year was the latest
+// year of the old inventory but is behind the latest year of the new one
+func DiffInventory(previous, current RawStations) (d InventoryDiff) {
+	oldStations := make(map[int]*StationMetadata, len(previous))
+	for i := range previous {
+		oldStations[previous[i].StationID] = &previous[i]
+	}
+	oldCurrent, newCurrent := previous.latestYear(), current.latestYear()
+
+	seen := make(map[int]bool, len(current))
+	for i := range current {
+		b := &current[i]
+		seen[b.StationID] = true
+
+		a, ok := oldStations[b.StationID]
+		if !ok {
+			d = append(d, StationChange{StationID: b.StationID, Name: b.Name, Kinds: []string{ChangeAdded}})
+			continue
+		}
+
+		if c, changed := diffStation(a, b, oldCurrent, newCurrent); changed {
+			d = append(d, c)
+		}
+	}
+
+	for i := range previous {
+		if a := &previous[i]; !seen[a.StationID] {
+			d = append(d, StationChange{StationID: a.StationID, Name: a.Name, Kinds: []string{ChangeRemoved}})
+		}
+	}
+
+	sort.SliceStable(d, func(i, j int) bool {
+		return d[i].StationID < d[j].StationID
+	})
+	return d
+}
+
+// diffStation compares the fields of a station in the old and new inventories
+func diffStation(a, b *StationMetadata, oldCurrent, newCurrent int) (c StationChange, changed bool) {
+	c = StationChange{StationID: b.StationID, Name: b.Name}
+	for _, f := range inventoryFields {
+		if o, n := f.value(a), f.value(b); o != n {
+			c.Fields = append(c.Fields, FieldChange{Field: f.name, Old: o, New: n})
+		}
+	}
+	closed := a.LastYear == oldCurrent && b.LastYear < newCurrent
+	if len(c.Fields) == 0 && !closed {
+		return c, false
+	}
+
+	if closed {
+		c.Kinds = append(c.Kinds, ChangeClosed)
+	}
+	if a.Latitude != b.Latitude || a.Longitude != b.Longitude {
+		c.Kinds = append(c.Kinds, ChangeMoved)
+		moved := *b
+		c.Moved = moved.Distance(a.Latitude, a.Longitude)
+	}
+	for _, r := range [][4]int{
+		{a.FirstYear, a.LastYear, b.FirstYear, b.LastYear},
+		{a.HourlyFirstYear, a.HourlyLastYear, b.HourlyFirstYear, b.HourlyLastYear},
+		{a.DailyFirstYear, a.DailyLastYear, b.DailyFirstYear, b.DailyLastYear},
+		{a.MonthlyFirstYear, a.MonthlyLastYear, b.MonthlyFirstYear, b.MonthlyLastYear},
+	} {
+		if extended(r[0], r[1], r[2], r[3]) {
+			c.Kinds = append(c.Kinds, ChangeExtended)
+			break
+		}
+	}
+	for _, f := range c.Fields {
+		if !strings.Contains(f.Field, "Year") && !strings.Contains(f.Field, "Decimal Degrees") {
+			c.Kinds = append(c.Kinds, ChangeModified)
+			break
+		}
+	}
+	if len(c.Kinds) == 0 {
+		// the years changed without growing, eg. a range corrected by ECCC
+		c.Kinds = append(c.Kinds, ChangeModified)
+	}
+	return c, true
+}
+
+// extended reports whether the range of years grew, a range of 0 has no data
+func extended(oldFirst, oldLast, newFirst, newLast int) bool {
+	if newFirst == 0 || newLast == 0 {
+		return false
+	}
+	if oldFirst == 0 || oldLast == 0 {
+		return true
+	}
+	return newFirst < oldFirst || newLast > oldLast
+}
+
+// latestYear returns the latest Last Year of the stations, the year of the current stations
+func (r RawStations) latestYear() (year int) {
+	for _, a := range r {
+		if a.LastYear > year {
+			year = a.LastYear
+		}
+	}
+	return year
+}
+
+// Count returns the number of changes of the kind
+func (d InventoryDiff) Count(kind string) (n int) {
+	for _, c := range d {
+		if c.Is(kind) {
+			n++
+		}
+	}
+	return n
+}
+
+// String returns a table of the changes followed by the number of changes of each kind
+func (d InventoryDiff) String() string {
+	a := "ID\tChange\t\tName\tFields\n"
+	for _, c := range d {
+		fields := make([]string, len(c.Fields))
+		for i, f := range c.Fields {
+			fields[i] = fmt.Sprintf("%s: %v -> %v", f.Field, f.Old, f.New)
+		}
+		if c.Moved != 0 {
+			fields = append(fields, fmt.Sprintf("moved %.2f km", c.Moved))
+		}
+		a += fmt.Sprintf("%d\t%-15s\t%s\t%s\n", c.StationID, strings.Join(c.Kinds, ","), c.Name, strings.Join(fields, "; "))
+	}
+
+	counts := []string{}
+	for _, kind := range []string{ChangeAdded, ChangeRemoved, ChangeClosed, ChangeMoved, ChangeExtended, ChangeModified} {
+		counts = append(counts, fmt.Sprintf("%d %s", d.Count(kind), kind))
+	}
+	a += fmt.Sprintf("%d stations changed: %s\n", len(d), strings.Join(counts, ", "))
+	return a
+}
+
+// MarshalJSON encodes the changes as an object keyed by StationID
+func (d InventoryDiff) MarshalJSON() ([]byte, error) {
+	m := make(map[string]StationChange, len(d))
+	for _, c := range d {
+		m[strconv.Itoa(c.StationID)] = c
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes the changes keyed by StationID, sorted by StationID
+func (d *InventoryDiff) UnmarshalJSON(b []byte) error {
+	m := map[string]StationChange{}
+	err := json.Unmarshal(b, &m)
+	if err != nil {
+		return err
+	}
+
+	*d = make(InventoryDiff, 0, len(m))
+	for _, c := range m {
+		*d = append(*d, c)
+	}
+	sort.Slice(*d, func(i, j int) bool {
+		return (*d)[i].StationID < (*d)[j].StationID
+	})
+	return nil
+}
@@ -0,0 +1,66 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffInventory(t *testing.T) {
+	previous, err := LoadInventory("_testdata/station-inventory.csv")
+	if !assert.NoError(t, err) || !assert.Len(t, previous, 4) {
+		return
+	}
+
+	current := append(RawStations{}, previous[:3]...)
+	current[0].Latitude = 48.88 // ACTIVE PASS moved
+	current[0].Elevation = 5
+	current[1].LastYear, current[1].DailyLastYear = 2023, 2023 // TORONTO CITY CENTRE extended, MONTRÉAL INT'L A closed
+	current = append(current, StationMetadata{StationID: 60000, Name: "NEW STATION", LastYear: 2023})
+
+	d := DiffInventory(previous, current)
+	if !assert.Len(t, d, 5) {
+		return
+	}
+
+	kinds := map[int][]string{}
+	for _, c := range d {
+		kinds[c.StationID] = c.Kinds
+	}
+	assert.Equal(t, map[int][]string{
+		14:    {ChangeMoved, ChangeModified},
+		30247: {ChangeExtended},
+		51157: {ChangeClosed},
+		50089: {ChangeRemoved},
+		60000: {ChangeAdded},
+	}, kinds)
+	assert.Equal(t, []FieldChange{
+		{"Latitude (Decimal Degrees)", 48.87, 48.88},
+		{"Elevation (m)", 4.0, 5.0},
+	}, d[0].Fields)
+	assert.InDelta(t, 1.11, d[0].Moved, 0.01)
+	assert.Equal(t, 1, d.Count(ChangeClosed))
+
+	table := d.String()
+	assert.Contains(t, table, "Last Year: 2022 -> 2023")
+	assert.Contains(t, table, "5 stations changed: 1 added, 1 removed, 1 closed, 1 moved, 1 extended, 1 modified")
+
+	b, err := json.Marshal(d)
+	if assert.NoError(t, err) {
+		fields := map[string]map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(b, &fields))
+		assert.Equal(t, "TORONTO CITY CENTRE", fields["30247"]["name"])
+		assert.True(t, strings.HasPrefix(string(b), "{"))
+
+		r := InventoryDiff{}
+		assert.NoError(t, json.Unmarshal(b, &r))
+		if assert.Len(t, r, 5) {
+			assert.Equal(t, d[1].StationID, r[1].StationID)
+			assert.Equal(t, d[1].Kinds, r[1].Kinds)
+		}
+	}
+
+	assert.Empty(t, DiffInventory(previous, previous))
+}
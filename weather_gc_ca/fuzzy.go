@@ -0,0 +1,137 @@
+package weather_gc_ca
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MinFuzzyScore is the lowest score of the names matched by a fuzzy search
+const MinFuzzyScore = 0.5
+
+// accents folds the accented letters of the station names to their base letter
+var accents = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u",
+	'ý': "y", 'ÿ': "y",
+}
+
+// normalizeName returns the name in lowercase without accents or punctuation, eg.
+// "ST. JOHN'S" and "st johns" are both "st johns", "MONTRÉAL" is "montreal".
+// Apostrophes are dropped, any other character that is not a letter or digit separates words
+func normalizeName(name string) string {
+	b := strings.Builder{}
+	space := false
+	for _, c := range strings.ToLower(name) {
+		if a, ok := accents[c]; ok {
+			b.WriteString(a)
+			space = false
+			continue
+		}
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			b.WriteRune(c)
+			space = false
+		case c == '\'' || c == '’':
+		case !space && b.Len() > 0:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), " ")
+}
+
+// FuzzyScore returns the relevance of a station name to a query from 0 to 1, both are compared
+// without case, accents or punctuation. The name equal to the query scores 1, a name starting
+// with the query scores above 0.9 and a name containing it above 0.8, the shorter names first.
+// Otherwise each word of the query is compared to the nearest word of the name by edit
+// distance, tolerating typos, and the name scores at most 0.8
+func FuzzyScore(query, name string) float64 {
+	q, n := normalizeName(query), normalizeName(name)
+	if q == "" || n == "" {
+		return 0
+	}
+
+	// the share of the name matched by the query ranks the shorter names first
+	cover := float64(len(q)) / float64(len(n))
+	switch {
+	case q == n:
+		return 1
+	case strings.HasPrefix(n, q):
+		return 0.9 + 0.09*cover
+	case strings.Contains(n, q):
+		return 0.8 + 0.09*cover
+	}
+
+	words := strings.Fields(n)
+	total := 0.0
+	for _, a := range strings.Fields(q) {
+		best := 0.0
+		for _, b := range words {
+			if s := similarity(a, b); s > best {
+				best = s
+			}
+		}
+		total += best
+	}
+	return 0.8 * total / float64(len(strings.Fields(q)))
+}
+
+// similarity returns 1 less the edit distance of the words relative to the longest of them
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	max := len(ra)
+	if len(rb) > max {
+		max = len(rb)
+	}
+	if max == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(max)
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and transpositions
+// of adjacent letters to change a into b (optimal string alignment distance)
+func editDistance(a, b []rune) int {
+	// d[i][j] is the distance between a[:i] and b[:j]
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a int, b ...int) int {
+	for _, v := range b {
+		if v < a {
+			a = v
+		}
+	}
+	return a
+}
+
+// NameFuzzy returns the max stations most relevant to the query, see StationQuery.NameFuzzy
+func (r RawStations) NameFuzzy(query string, max int) (s RawStations) {
+	return r.Query(NewStationQuery().NameFuzzy(query).Limit(max))
+}
@@ -0,0 +1,62 @@
+package weather_gc_ca
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	assert.Equal(t, "st johns a", normalizeName("ST. JOHN'S A"))
+	assert.Equal(t, "montreal intl a", normalizeName("MONTRÉAL INT'L A"))
+	assert.Equal(t, "ile aux coudres", normalizeName("Île-aux-Coudres"))
+	assert.Equal(t, 3, editDistance([]rune("kitten"), []rune("sitting")))
+	assert.Equal(t, 1, editDistance([]rune("toronto"), []rune("torotno")))
+
+	tests := []struct {
+		query, name string
+		min, max    float64
+	}{
+		{"st johns a", "ST. JOHN'S A", 1, 1},
+		{"st johns", "ST. JOHN'S A", 0.9, 1},
+		{"Montreal", "MONTRÉAL A", 0.9, 1},
+		{"city", "TORONTO CITY CENTRE", 0.8, 0.9},
+		{"torotno", "TORONTO A", MinFuzzyScore, 0.8},
+		{"centre toronto", "TORONTO CITY CENTRE", 0.8, 0.8},
+		{"vancouver", "TORONTO A", 0, MinFuzzyScore},
+		{"", "TORONTO A", 0, 0},
+	}
+	for _, tt := range tests {
+		score := FuzzyScore(tt.query, tt.name)
+		assert.GreaterOrEqualf(t, score, tt.min, "%q in %q", tt.query, tt.name)
+		assert.LessOrEqualf(t, score, tt.max, "%q in %q", tt.query, tt.name)
+	}
+	assert.Greater(t, FuzzyScore("regina", "REGINA A"), FuzzyScore("regina", "REGINA RCS"), "shorter names first")
+}
+
+func TestNameFuzzy(t *testing.T) {
+	for _, query := range []string{"st johns", "ST. JOHN'S", "montreal", "MONTRÉAL", "montral"} {
+		s := StationInventory.NameFuzzy(query, 10)
+		if assert.NotEmpty(t, s, query) {
+			assert.Len(t, s, 10)
+			for i, a := range s {
+				assert.GreaterOrEqual(t, a.previousScore, MinFuzzyScore)
+				if i > 0 {
+					assert.GreaterOrEqual(t, s[i-1].previousScore, a.previousScore, "sorted by relevance")
+				}
+			}
+		}
+	}
+
+	s := StationInventory.NameFuzzy("torotno", 0)
+	if assert.NotEmpty(t, s) {
+		assert.Equal(t, "TORONTO A", s[0].Name)
+	}
+
+	s = StationInventory.Query(NewStationQuery().NameFuzzy("montreal").Sort(SortByName))
+	for i := range s {
+		if i > 0 {
+			assert.LessOrEqual(t, s[i-1].Name, s[i].Name, "sorted by name")
+		}
+	}
+}
@@ -1,36 +1,104 @@
 package weather_gc_ca
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 // SearchHandler processes a standard search request and returns a JSON response
-// corresponding to []StationMetadata
+// corresponding to []StationMetadata. The query parameters build a StationQuery:
+// the max stations nearest to lat & lng, the stations within radius km of lat & lng or the
+// stations inside bbox (minLat,minLng,maxLat,maxLng, eg. the viewport of a map), combined with
+// province, name, ids, minElevation & maxElevation. With fuzzy=true the name is matched ignoring
+// accents, punctuation and typos, most relevant first. The stations are filtered by interval
+// (default daily) and by the years of data from & to, covered by every year or by at least
+// minYears of them, and sorted by sort, max & offset select a page of the results.
+// The stations are written as format: json (default), geojson, ndjson or csv.
+// A POST request searches the stations inside the GeoJSON Polygon or MultiPolygon of its body
 func SearchHandler(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	latS := q.Get("lat")
-	lat, err := strconv.ParseFloat(latS, 64)
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = FormatJSON
+	}
+	contentType := ContentType(format)
+	if contentType == "" {
+		http.Error(w, fmt.Sprintf("invalid format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	query, err := searchQuery(w, r)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to parse lat: %s", err.Error()), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	lngS := q.Get("lng")
-	lng, err := strconv.ParseFloat(lngS, 64)
+	s := Inventory().Query(query)
+	if s == nil || len(s) == 0 {
+		http.Error(w, "No stations found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	err = s.Write(w, format)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to parse lng: %s", err.Error()), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("failed to write response: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
+}
 
-	maxS := q.Get("max")
-	max, err := strconv.ParseInt(maxS, 10, 16)
+// StationHandler returns the JSON of the stations with an identifier, corresponding to
+// []StationMetadata. The identifier is one of the query parameters: stationID, climateID,
+// wmoID, tcID or id, which is resolved as any of them (see RawStations.Lookup).
+// A WMO or TC ID can be kept by several stations, the stations with the most recent data are first
+func StationHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var s RawStations
+	found := false
+	for _, p := range []struct{ param, idType string }{
+		{"stationID", IDTypeStation},
+		{"climateID", IDTypeClimate},
+		{"wmoID", IDTypeWMO},
+		{"tcID", IDTypeTC},
+	} {
+		if id := q.Get(p.param); id != "" {
+			s, found = Inventory().LookupType(p.idType, id), true
+			break
+		}
+	}
+
+	if !found {
+		id := q.Get("id")
+		if id == "" {
+			http.Error(w, "failed to parse id: one of id, stationID, climateID, wmoID or tcID is required", http.StatusBadRequest)
+			return
+		}
+		s, _ = Inventory().Lookup(id)
+	}
+
+	if len(s) == 0 {
+		http.Error(w, "No stations found", http.StatusNotFound)
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(s)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to parse max: %s", err.Error()), http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("failed to write response: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
+}
+
+// searchQuery builds the StationQuery of the parameters of a search request
+func searchQuery(w http.ResponseWriter, r *http.Request) (*StationQuery, error) {
+	q := r.URL.Query()
+	query := NewStationQuery()
 
 	interval := Daily
 	intervalS := q.Get("interval")
@@ -41,21 +109,411 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		interval = Interval(intParsed)
 	}
+	query.Interval(interval)
 
-	s := StationInventory.FindWithInterval(lat, lng, int(max), interval)
-	if s == nil || len(s) == 0 {
-		http.Error(w, "No stations found", http.StatusNotFound)
-		return
+	maxS := q.Get("max")
+	if maxS != "" {
+		max, err := strconv.ParseInt(maxS, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse max: %s", err)
+		}
+		query.Limit(int(max))
+	}
+
+	if offsetS := q.Get("offset"); offsetS != "" {
+		offset, err := strconv.Atoi(offsetS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse offset: %s", err)
+		}
+		query.Offset(offset)
+	}
+
+	if sortS := q.Get("sort"); sortS != "" {
+		query.Sort(SortByString(sortS))
 	}
 
-	err = json.NewEncoder(w).Encode(s)
+	if provinces := q["province"]; len(provinces) > 0 {
+		query.Province(strings.Split(strings.Join(provinces, ","), ",")...)
+	}
+
+	if name := q.Get("name"); name != "" {
+		fuzzy := false
+		if fuzzyS := q.Get("fuzzy"); fuzzyS != "" {
+			var err error
+			fuzzy, err = strconv.ParseBool(fuzzyS)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse fuzzy: %s", err)
+			}
+		}
+		if fuzzy {
+			query.NameFuzzy(name)
+		} else {
+			query.NameContains(name)
+		}
+	}
+
+	if idsS := q.Get("ids"); idsS != "" {
+		ids := []int{}
+		for _, a := range strings.Split(idsS, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(a))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ids: %s", err)
+			}
+			ids = append(ids, id)
+		}
+		query.IDs(ids...)
+	}
+
+	minElevS, maxElevS := q.Get("minElevation"), q.Get("maxElevation")
+	if minElevS != "" || maxElevS != "" {
+		minElev, maxElev := math.Inf(-1), math.Inf(1)
+		var err error
+		if minElevS != "" {
+			minElev, err = strconv.ParseFloat(minElevS, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse minElevation: %s", err)
+			}
+		}
+		if maxElevS != "" {
+			maxElev, err = strconv.ParseFloat(maxElevS, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse maxElevation: %s", err)
+			}
+		}
+		query.Elevation(minElev, maxElev)
+	}
+
+	fromS, toS := q.Get("from"), q.Get("to")
+	if fromS != "" || toS != "" {
+		if fromS == "" || toS == "" {
+			return nil, fmt.Errorf("failed to parse coverage: from and to are required")
+		}
+		from, err := strconv.Atoi(fromS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse from: %s", err)
+		}
+		to, err := strconv.Atoi(toS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse to: %s", err)
+		}
+		years := 0
+		if yearsS := q.Get("minYears"); yearsS != "" {
+			years, err = strconv.Atoi(yearsS)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse minYears: %s", err)
+			}
+		}
+		query.Overlapping(from, to, years)
+	}
+
+	if r.Method == http.MethodPost {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolygonSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request: %s", err)
+		}
+		polygon, err := ParsePolygon(b)
+		if err != nil {
+			return nil, err
+		}
+		query.Polygon(polygon)
+	}
+
+	if bboxS := q.Get("bbox"); bboxS != "" {
+		bbox, err := parseFloats(bboxS, 4)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bbox: %s", err)
+		}
+		query.BBox(bbox[0], bbox[1], bbox[2], bbox[3])
+	}
+
+	latS, lngS := q.Get("lat"), q.Get("lng")
+	if latS == "" && lngS == "" {
+		return query, nil
+	}
+
+	lat, err := strconv.ParseFloat(latS, 64)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to write response: %s", err.Error()), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to parse lat: %s", err)
+	}
+
+	lng, err := strconv.ParseFloat(lngS, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lng: %s", err)
+	}
+
+	radiusS := q.Get("radius")
+	if radiusS == "" {
+		if maxS == "" {
+			return nil, fmt.Errorf("failed to parse max: max is required")
+		}
+		return query.Near(lat, lng), nil
+	}
+
+	radius, err := strconv.ParseFloat(radiusS, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse radius: %s", err)
 	}
+	return query.Within(lat, lng, radius), nil
+}
+
+// maxPolygonSize is the maximum size of the GeoJSON of a POST search
+const maxPolygonSize = 10 << 20
 
+// parseFloats parses n comma separated numbers
+func parseFloats(a string, n int) ([]float64, error) {
+	parts := strings.Split(a, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d values, got %d", n, len(parts))
+	}
+
+	f := make([]float64, n)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		f[i] = v
+	}
+	return f, nil
 }
 
+// DownloadFailedTrailer is the trailer of a DownloadHandler response listing the periods
+// that failed to download, eg. 1993-02,1993-07, the data of the response is incomplete
+const DownloadFailedTrailer = "X-Download-Failed"
+
+// DownloadHandler retreives the data for a station and streams it as CSV or JSON as each
+// dataset is downloaded. The request is defined by the query parameters:
+// stationID, interval (name or number, default daily), start & end (years, default the
+// station timeframe), format (csv or json, default from the Accept header) and time (lst
+// or utc, the time of the hourly records, default lst).
+// A response without data is 502 if any dataset failed to download and 404 otherwise,
+// the periods that failed after data was streamed are listed in the DownloadFailedTrailer.
+// Closing the client connection cancels the download.
 func DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	stnS := q.Get("stationID")
+	stn, err := strconv.Atoi(stnS)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse stationID: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
 
+	s, ok := Inventory().Station(stn)
+	if !ok {
+		http.Error(w, fmt.Sprintf("station %d not found", stn), http.StatusNotFound)
+		return
+	}
+
+	interval := Daily
+	intervalS := q.Get("interval")
+	if intervalS != "" {
+		interval = IntervalString(intervalS)
+		if interval == 0 {
+			http.Error(w, fmt.Sprintf("invalid interval: %s", intervalS), http.StatusBadRequest)
+			return
+		}
+	}
+
+	startYear, endYear := s.Timeframe(interval)
+	if startYear == 0 || endYear == 0 {
+		http.Error(w, fmt.Sprintf("station %d has no %s data", stn, interval), http.StatusNotFound)
+		return
+	}
+
+	start := Timeframe{Year: startYear, Month: 1, Day: 1}
+	if startS := q.Get("start"); startS != "" {
+		start.Year, err = strconv.Atoi(startS)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse start: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if start.Year < startYear {
+			http.Error(w, fmt.Sprintf("start year %d is before station start year %d", start.Year, startYear), http.StatusBadRequest)
+			return
+		}
+	}
+
+	end := Timeframe{Year: endYear, Month: 12, Day: 31}
+	if endS := q.Get("end"); endS != "" {
+		end.Year, err = strconv.Atoi(endS)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to parse end: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+		if end.Year > endYear {
+			http.Error(w, fmt.Sprintf("end year %d is after station end year %d", end.Year, endYear), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if start.Year > end.Year {
+		http.Error(w, fmt.Sprintf("start year %d is after end year %d", start.Year, end.Year), http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(q.Get("format"))
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = "csv"
+		}
+	}
+
+	utc := false
+	switch timeS := strings.ToLower(q.Get("time")); timeS {
+	case "", "lst":
+	case "utc":
+		utc = true
+	default:
+		http.Error(w, fmt.Sprintf("invalid time: %s", timeS), http.StatusBadRequest)
+		return
+	}
+
+	var out chunkWriter
+	switch format {
+	case "csv":
+		out = &csvChunkWriter{w: csv.NewWriter(w)}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+			fmt.Sprintf("%s_%d_%s_%d-%d.csv", s.Name, s.StationID, interval, start.Year, end.Year)))
+	case "json":
+		out = &jsonChunkWriter{w: w}
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		http.Error(w, fmt.Sprintf("invalid format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	// the periods that failed are only known once the data was streamed
+	w.Header().Set("Trailer", DownloadFailedTrailer)
+
+	// the request context is cancelled when the client disconnects, which stops the download
+	ctx := r.Context()
+	status := s.RetreiveTimeframe(ctx, start, end, interval)
+
+	written := false
+	for {
+		select {
+		case p := <-status.Progress:
+			if p.Error != nil || p.Data == nil || p.Data.Empty() {
+				continue
+			}
+
+			data := p.Data
+			if utc {
+				data = InUTC(data)
+			}
+
+			err = out.write(data)
+			if err != nil {
+				// the connection is most likely gone, the context will stop the download
+				return
+			}
+			written = true
+
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		case <-status.Done:
+			failed := status.Failed()
+			if !written {
+				if ctx.Err() != nil {
+					return
+				}
+				w.Header().Del("Trailer")
+				w.Header().Del("Content-Disposition")
+				if len(failed) > 0 {
+					// every dataset with data failed upstream, this is not a station without data
+					msg := []string{}
+					for _, f := range failed {
+						msg = append(msg, f.Error())
+					}
+					http.Error(w, strings.Join(msg, "\n"), http.StatusBadGateway)
+					return
+				}
+				http.Error(w, "No data found", http.StatusNotFound)
+				return
+			}
+			out.close()
+			if len(failed) > 0 {
+				periods := []string{}
+				for _, f := range failed {
+					periods = append(periods, fmt.Sprintf("%d-%02d", f.Year, f.Month))
+				}
+				w.Header().Set(DownloadFailedTrailer, strings.Join(periods, ","))
+			}
+			return
+		}
+	}
+}
+
+// chunkWriter writes each downloaded dataset to the response as it arrives
+type chunkWriter interface {
+	write(StationDataXML) error
+	close() error
+}
+
+type csvChunkWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (c *csvChunkWriter) write(data StationDataXML) error {
+	rows := data.csv()
+	if c.header {
+		rows = rows[1:]
+	}
+	c.header = true
+
+	err := c.w.WriteAll(rows)
+	if err != nil {
+		return fmt.Errorf("failed to write csv: %s", err)
+	}
+	return nil
+}
+
+func (c *csvChunkWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonChunkWriter streams the datasets as a single JSON array
+type jsonChunkWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonChunkWriter) write(data StationDataXML) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to write json: %s", err)
+	}
+
+	// strip the enclosing brackets to join the records into a single array
+	b = bytes.TrimSpace(b)
+	b = bytes.TrimSuffix(bytes.TrimPrefix(b, []byte("[")), []byte("]"))
+	if len(b) == 0 {
+		return nil
+	}
+
+	prefix := ","
+	if j.count == 0 {
+		prefix = "["
+	}
+	j.count++
+
+	_, err = j.w.Write(append([]byte(prefix), b...))
+	if err != nil {
+		return fmt.Errorf("failed to write json: %s", err)
+	}
+	return nil
+}
+
+func (j *jsonChunkWriter) close() error {
+	a := "]\n"
+	if j.count == 0 {
+		a = "[]\n"
+	}
+	_, err := io.WriteString(j.w, a)
+	return err
 }
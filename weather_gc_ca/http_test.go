@@ -0,0 +1,248 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"missing station", "", http.StatusBadRequest},
+		{"unknown station", "?stationID=-1", http.StatusNotFound},
+		{"invalid interval", "?stationID=30247&interval=weekly", http.StatusBadRequest},
+		{"start before station", "?stationID=30247&interval=almanac&start=1700", http.StatusBadRequest},
+		{"end after station", "?stationID=30247&interval=almanac&end=3000", http.StatusBadRequest},
+		{"invalid format", "?stationID=30247&interval=almanac&format=xml", http.StatusBadRequest},
+		{"invalid time", "?stationID=30247&interval=almanac&time=est", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/station/download/"+tt.query, nil)
+			w := httptest.NewRecorder()
+			DownloadHandler(w, req)
+			assert.Equalf(t, tt.code, w.Code, "unexpected status: %s", w.Body.String())
+		})
+	}
+}
+
+func TestDownloadHandlerYears(t *testing.T) {
+	requests := useTestClient(t)
+	prev := Inventory()
+	SetInventory(append(RawStations{{Name: "TORONTO", Province: "ONTARIO", StationID: 51459, DailyFirstYear: 1992, DailyLastYear: 1992}}, prev...))
+	t.Cleanup(func() { SetInventory(prev) })
+
+	for _, query := range []string{
+		"?stationID=51459&interval=daily&start=1992&end=1992",
+		// the end year defaults to the last year of the station
+		"?stationID=51459&interval=daily",
+	} {
+		atomic.StoreInt32(requests, 0)
+		req := httptest.NewRequest("GET", "/station/download/"+query, nil)
+		w := httptest.NewRecorder()
+		DownloadHandler(w, req)
+		if !assert.Equalf(t, http.StatusOK, w.Code, "%s: %s", query, w.Body.String()) {
+			continue
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests), query)
+
+		d := DailyDataXML{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &d))
+		if assert.Len(t, d, 366, query) {
+			assert.Equal(t, 1992, d[0].Year)
+			assert.Equal(t, 1992, d[365].Year)
+		}
+	}
+}
+
+func TestDownloadHandlerFailures(t *testing.T) {
+	// the 1993 hourly datasets of the months in fail are unavailable
+	fail := map[string]bool{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if fail[q.Get("Month")] {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, `<climatedata><stationdata timetype="LST" day="1" hour="0" minute="0" month="%s" year="%s"><temp>1.0</temp></stationdata></climatedata>`,
+			q.Get("Month"), q.Get("Year"))
+	}))
+	defer s.Close()
+
+	prevClient := DefaultClient
+	DefaultClient = NewClient().HTTPClient(s.Client()).BaseURL(s.URL).Retries(0)
+	prev := Inventory()
+	SetInventory(append(RawStations{{Name: "TORONTO", Province: "ONTARIO", StationID: 51459, HourlyFirstYear: 1993, HourlyLastYear: 1993}}, prev...))
+	t.Cleanup(func() {
+		DefaultClient = prevClient
+		SetInventory(prev)
+	})
+
+	download := func() *http.Response {
+		req := httptest.NewRequest("GET", "/station/download/?stationID=51459&interval=hourly&start=1993&end=1993", nil)
+		w := httptest.NewRecorder()
+		DownloadHandler(w, req)
+		return w.Result()
+	}
+
+	t.Run("partial", func(t *testing.T) {
+		fail = map[string]bool{"2": true, "7": true}
+		res := download()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "1993-02,1993-07", res.Trailer.Get(DownloadFailedTrailer))
+
+		h := HourlyDataXML{}
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&h))
+		assert.Len(t, h, 10)
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		fail = map[string]bool{}
+		res := download()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Trailer.Get(DownloadFailedTrailer))
+	})
+
+	t.Run("outage", func(t *testing.T) {
+		fail = map[string]bool{}
+		for m := 1; m <= 12; m++ {
+			fail[strconv.Itoa(m)] = true
+		}
+		res := download()
+		assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+		b, _ := io.ReadAll(res.Body)
+		assert.Equal(t, 12, strings.Count(string(b), "failed to retreive"))
+	})
+}
+
+func TestSearchHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		count int
+	}{
+		{"nearest", "?lat=50.4452&lng=-104.6189&max=5", http.StatusOK, 5},
+		{"missing max", "?lat=50.4452&lng=-104.6189", http.StatusBadRequest, 0},
+		{"radius", "?lat=50.4452&lng=-104.6189&radius=100&interval=0", http.StatusOK, len(StationInventory.WithinRadius(50.4452, -104.6189, 100))},
+		{"radius max", "?lat=50.4452&lng=-104.6189&radius=100&interval=0&max=3", http.StatusOK, 3},
+		{"invalid radius", "?lat=50.4452&lng=-104.6189&radius=far", http.StatusBadRequest, 0},
+		{"bbox", "?bbox=50,-105,51,-104&interval=0", http.StatusOK, len(StationInventory.WithinBBox(50, -105, 51, -104))},
+		{"invalid bbox", "?bbox=50,-105,51", http.StatusBadRequest, 0},
+		{"empty bbox", "?bbox=89,0,89.5,1", http.StatusNotFound, 0},
+		{"province", "?province=alberta&interval=0", http.StatusOK, len(StationInventory.Query(NewStationQuery().Province("ALBERTA")))},
+		{"province bbox", "?province=SASKATCHEWAN,MANITOBA&bbox=49,-110,60,-95&minElevation=500", http.StatusOK,
+			len(StationInventory.Query(NewStationQuery().Interval(Daily).Province("SASKATCHEWAN", "MANITOBA").BBox(49, -110, 60, -95).Elevation(500, math.Inf(1))))},
+		{"page", "?lat=50.4452&lng=-104.6189&max=5&offset=5", http.StatusOK, 5},
+		{"ids", "?ids=30247,-1&interval=0", http.StatusOK, 1},
+		{"invalid ids", "?ids=a", http.StatusBadRequest, 0},
+		{"invalid elevation", "?maxElevation=high", http.StatusBadRequest, 0},
+		{"name", "?name=montreal&interval=0", http.StatusNotFound, 0},
+		{"fuzzy name", "?name=montreal&fuzzy=true&interval=0&max=3", http.StatusOK, 3},
+		{"coverage", "?from=1981&to=2010&province=ONTARIO", http.StatusOK,
+			len(StationInventory.Query(NewStationQuery().Interval(Daily).Province("ONTARIO").Covering(1981, 2010)))},
+		{"overlap", "?from=1981&to=2010&minYears=10&province=ONTARIO", http.StatusOK,
+			len(StationInventory.Query(NewStationQuery().Interval(Daily).Province("ONTARIO").Overlapping(1981, 2010, 10)))},
+		{"missing to", "?from=1981", http.StatusBadRequest, 0},
+		{"invalid from", "?from=a&to=2010", http.StatusBadRequest, 0},
+		{"invalid fuzzy", "?name=montreal&fuzzy=maybe", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/station/search/"+tt.query, nil)
+			w := httptest.NewRecorder()
+			SearchHandler(w, req)
+			if !assert.Equalf(t, tt.code, w.Code, "unexpected status: %s", w.Body.String()) || tt.code != http.StatusOK {
+				return
+			}
+
+			s := []map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+			assert.Len(t, s, tt.count)
+		})
+	}
+}
+
+func TestSearchHandlerPolygon(t *testing.T) {
+	m, err := ParsePolygon([]byte(testPolygon))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest("POST", "/station/search/?interval=0", strings.NewReader(testPolygon))
+	w := httptest.NewRecorder()
+	SearchHandler(w, req)
+	if assert.Equalf(t, http.StatusOK, w.Code, "unexpected status: %s", w.Body.String()) {
+		s := []map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+		assert.Len(t, s, len(StationInventory.WithinPolygon(m)))
+	}
+
+	req = httptest.NewRequest("POST", "/station/search/", strings.NewReader(`{"type": "Point"}`))
+	w = httptest.NewRecorder()
+	SearchHandler(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStationHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"missing id", "", http.StatusBadRequest},
+		{"station id", "?stationID=30247", http.StatusOK},
+		{"climate id", "?climateID=6158359", http.StatusOK},
+		{"wmo id", "?wmoID=71265", http.StatusOK},
+		{"tc id", "?tcID=ytz", http.StatusOK},
+		{"any id", "?id=YTZ", http.StatusOK},
+		{"unknown id", "?id=unknown", http.StatusNotFound},
+		{"invalid station id", "?stationID=YTZ", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/station/info/"+tt.query, nil)
+			w := httptest.NewRecorder()
+			StationHandler(w, req)
+			if !assert.Equalf(t, tt.code, w.Code, "unexpected status: %s", w.Body.String()) || tt.code != http.StatusOK {
+				return
+			}
+
+			s := []map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &s))
+			found := false
+			for _, a := range s {
+				if a["stationID"] == 30247.0 {
+					found = true
+					assert.Equal(t, "6158359", a["climateID"])
+					assert.Equal(t, "YTZ", a["tcID"])
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestIntervalString(t *testing.T) {
+	assert.Equal(t, Hourly, IntervalString("hourly"))
+	assert.Equal(t, Daily, IntervalString("2"))
+	assert.Equal(t, Monthly, IntervalString("Monthly"))
+	assert.Equal(t, Almanac, IntervalString("almanac"))
+	assert.Equal(t, Interval(0), IntervalString("weekly"))
+}
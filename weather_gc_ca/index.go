@@ -0,0 +1,263 @@
+package weather_gc_ca
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// stationIndex is a k-d tree over the stations, built once and queried for the k-nearest
+// stations. The stations are indexed by their position on the unit sphere, the chord between
+// two points grows with the great-circle distance so the nearest stations by chord are the
+// nearest by Distance, without the discontinuity of longitudes at the antimeridian.
+// The stations are also indexed by each of their identifiers
+type stationIndex struct {
+	stations RawStations
+	// points is an implicit tree, the node of the range [lo, hi) is at (lo+hi)/2
+	// and splits the range on the axis of its depth
+	points []indexPoint
+
+	// the positions in stations of each identifier
+	stationIDs map[int]int
+	climateIDs map[string][]int
+	wmoIDs     map[string][]int
+	tcIDs      map[string][]int
+}
+
+type indexPoint struct {
+	xyz     [3]float64
+	station int // position in stations
+}
+
+// inventoryIndex is the index of StationInventory, built by SetInventory
+var inventoryIndex *stationIndex
+
+// newStationIndex builds the index of the stations, the index refers to the
+// stations so they must not be modified while the index is in use
+func newStationIndex(r RawStations) *stationIndex {
+	idx := &stationIndex{
+		stations:   r,
+		points:     make([]indexPoint, len(r)),
+		stationIDs: make(map[int]int, len(r)),
+		climateIDs: make(map[string][]int, len(r)),
+		wmoIDs:     map[string][]int{},
+		tcIDs:      map[string][]int{},
+	}
+	for i, a := range r {
+		idx.points[i] = indexPoint{xyz: unitVector(a.Latitude, a.Longitude), station: i}
+
+		if _, ok := idx.stationIDs[a.StationID]; !ok {
+			idx.stationIDs[a.StationID] = i
+		}
+		for _, id := range []struct {
+			m  map[string][]int
+			id string
+		}{{idx.climateIDs, a.ClimateID}, {idx.wmoIDs, a.WMOID}, {idx.tcIDs, a.TCID}} {
+			if key := normalizeID(id.id); key != "" {
+				id.m[key] = append(id.m[key], i)
+			}
+		}
+	}
+	idx.build(0, len(idx.points), 0)
+	return idx
+}
+
+func (idx *stationIndex) build(lo, hi, depth int) {
+	if hi-lo < 2 {
+		return
+	}
+
+	axis := depth % 3
+	p := idx.points[lo:hi]
+	sort.Slice(p, func(i, j int) bool {
+		return p[i].xyz[axis] < p[j].xyz[axis]
+	})
+
+	mid := (lo + hi) / 2
+	idx.build(lo, mid, depth+1)
+	idx.build(mid+1, hi, depth+1)
+}
+
+// nearest returns the k stations nearest to the point that are accepted by keep, nearest
+// first, see nearestSet for the stations at the same distance. A nil keep accepts every station
+func (idx *stationIndex) nearest(lat, lng float64, k int, keep func(a *StationMetadata) bool) RawStations {
+	if k <= 0 {
+		return nil
+	}
+
+	q := unitVector(lat, lng)
+	n := &nearestSet{k: k}
+	idx.search(0, len(idx.points), 0, q, keep, n)
+
+	found := n.neighbours()
+	s := make(RawStations, len(found))
+	for i, a := range found {
+		s[i] = idx.stations[a.station]
+		s[i].Distance(lat, lng)
+	}
+	return s
+}
+
+func (idx *stationIndex) search(lo, hi, depth int, q [3]float64, keep func(a *StationMetadata) bool, n *nearestSet) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	p := idx.points[mid]
+	if a := &idx.stations[p.station]; keep == nil || keep(a) {
+		n.offer(neighbour{station: p.station, id: a.StationID, key: chord(q, p.xyz)})
+	}
+
+	// search the side of the point first, the other side is only searched
+	// if it can hold a station as near as the farthest one found
+	axis := depth % 3
+	diff := q[axis] - p.xyz[axis]
+	if diff < 0 {
+		idx.search(lo, mid, depth+1, q, keep, n)
+		if !n.full() || diff*diff <= n.farthest() {
+			idx.search(mid+1, hi, depth+1, q, keep, n)
+		}
+		return
+	}
+
+	idx.search(mid+1, hi, depth+1, q, keep, n)
+	if !n.full() || diff*diff <= n.farthest() {
+		idx.search(lo, mid, depth+1, q, keep, n)
+	}
+}
+
+// within returns the position in stations of every station accepted by keep within the
+// squared chord of the point
+func (idx *stationIndex) within(q [3]float64, limit float64, keep func(a *StationMetadata) bool) []int {
+	found := []int{}
+	idx.searchWithin(0, len(idx.points), 0, q, limit, keep, &found)
+	return found
+}
+
+func (idx *stationIndex) searchWithin(lo, hi, depth int, q [3]float64, limit float64, keep func(a *StationMetadata) bool, found *[]int) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	p := idx.points[mid]
+	if chord(q, p.xyz) <= limit && (keep == nil || keep(&idx.stations[p.station])) {
+		*found = append(*found, p.station)
+	}
+
+	axis := depth % 3
+	diff := q[axis] - p.xyz[axis]
+	if diff < 0 || diff*diff <= limit {
+		idx.searchWithin(lo, mid, depth+1, q, limit, keep, found)
+	}
+	if diff >= 0 || diff*diff <= limit {
+		idx.searchWithin(mid+1, hi, depth+1, q, limit, keep, found)
+	}
+}
+
+// chordLimit returns the squared chord on the unit sphere of a great-circle distance in km
+func chordLimit(km float64) float64 {
+	angle := km / earthRadius
+	if angle >= math.Pi {
+		return 4
+	}
+	c := 2 * math.Sin(angle/2)
+	return c * c
+}
+
+// unitVector returns the position of the coordinates on the unit sphere
+func unitVector(lat, lng float64) [3]float64 {
+	phi, lambda := lat*math.Pi/180, lng*math.Pi/180
+	return [3]float64{
+		math.Cos(phi) * math.Cos(lambda),
+		math.Cos(phi) * math.Sin(lambda),
+		math.Sin(phi),
+	}
+}
+
+// chord returns the squared length of the chord between two points of the unit sphere
+func chord(a, b [3]float64) float64 {
+	x, y, z := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return x*x + y*y + z*z
+}
+
+// neighbour is a station found by a nearest search, the key orders the neighbours by distance
+type neighbour struct {
+	station int // position in the stations searched
+	id      int // StationID, orders the neighbours at the same distance
+	key     float64
+}
+
+func (a neighbour) before(b neighbour) bool {
+	if a.key == b.key {
+		return a.id < b.id
+	}
+	return a.key < b.key
+}
+
+// neighbourHeap is a max heap of the nearest stations found, the farthest is on top
+type neighbourHeap []neighbour
+
+func (h neighbourHeap) Len() int            { return len(h) }
+func (h neighbourHeap) Less(i, j int) bool  { return h[j].before(h[i]) }
+func (h neighbourHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighbourHeap) Push(x interface{}) { *h = append(*h, x.(neighbour)) }
+func (h *neighbourHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// nearestSet collects the k nearest stations offered. Stations at the same distance as
+// the farthest of the k nearest are all kept, co-located stations are never dropped so
+// more than k stations are returned when they share that distance
+type nearestSet struct {
+	k    int
+	heap neighbourHeap
+	ties []neighbour // stations left out of the heap that may share the farthest distance
+}
+
+func (n *nearestSet) full() bool {
+	return len(n.heap) >= n.k
+}
+
+func (n *nearestSet) farthest() float64 {
+	return n.heap[0].key
+}
+
+func (n *nearestSet) offer(a neighbour) {
+	if !n.full() {
+		heap.Push(&n.heap, a)
+		return
+	}
+
+	switch f := n.heap[0]; {
+	case a.key < f.key:
+		n.heap[0] = a
+		heap.Fix(&n.heap, 0)
+		n.ties = append(n.ties, f)
+	case a.key == f.key:
+		n.ties = append(n.ties, a)
+	}
+}
+
+// neighbours returns the stations found nearest first, the stations at the
+// same distance are ordered by StationID
+func (n *nearestSet) neighbours() []neighbour {
+	found := append([]neighbour{}, n.heap...)
+	if len(found) > 0 {
+		far := n.farthest()
+		for _, a := range n.ties {
+			if a.key == far {
+				found = append(found, a)
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].before(found[j])
+	})
+	return found
+}
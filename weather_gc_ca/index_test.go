@@ -0,0 +1,139 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationIndex(t *testing.T) {
+	if !assert.NotNil(t, StationInventory.index(), "the inventory is indexed at init") {
+		return
+	}
+	assert.Nil(t, StationInventory[1:].index())
+
+	distances := func(s RawStations) []float64 {
+		d := make([]float64, len(s))
+		for i, a := range s {
+			d[i] = a.SearchDistance()
+		}
+		sort.Float64s(d)
+		return d
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		lat, lng := rnd.Float64()*180-90, rnd.Float64()*360-180
+		k := rnd.Intn(50) + 1
+		interval := Interval(rnd.Intn(5))
+		keep := func(a *StationMetadata) bool { return a.HasInterval(interval) }
+
+		s := StationInventory.FindWithInterval(lat, lng, k, interval)
+		linear := StationInventory.findLinear(lat, lng, k, keep)
+		if !assert.Equal(t, len(linear), len(s), "%.2f,%.2f k=%d %s", lat, lng, k, interval) {
+			continue
+		}
+		assert.InDeltaSlice(t, distances(linear), distances(s), 1e-6, "%.2f,%.2f k=%d %s", lat, lng, k, interval)
+
+		// nearest first
+		assert.True(t, sort.SliceIsSorted(s, func(i, j int) bool {
+			return s[i].SearchDistance() < s[j].SearchDistance()
+		}))
+		for _, a := range s {
+			assert.True(t, a.HasInterval(interval))
+		}
+	}
+
+	t.Run("antimeridian", func(t *testing.T) {
+		r := RawStations{
+			{StationID: 1, Latitude: 0, Longitude: 179.9},
+			{StationID: 2, Latitude: 0, Longitude: -179.9},
+			{StationID: 3, Latitude: 0, Longitude: 170},
+		}
+		s := newStationIndex(r).nearest(0, -179.95, 2, nil)
+		if assert.Len(t, s, 2) {
+			assert.Equal(t, 2, s[0].StationID)
+			assert.Equal(t, 1, s[1].StationID)
+		}
+	})
+
+	t.Run("ties", func(t *testing.T) {
+		// co-located stations at the same distance
+		r := RawStations{
+			{StationID: 5, Latitude: 50, Longitude: -100},
+			{StationID: 3, Latitude: 50.5, Longitude: -100},
+			{StationID: 4, Latitude: 50, Longitude: -100},
+			{StationID: 1, Latitude: 50.5, Longitude: -100},
+			{StationID: 2, Latitude: 51, Longitude: -100},
+		}
+		ids := func(s RawStations) (a []int) {
+			for _, st := range s {
+				a = append(a, st.StationID)
+			}
+			return a
+		}
+
+		for name, find := range map[string]func(k int) RawStations{
+			"index":  func(k int) RawStations { return newStationIndex(r).nearest(49.9, -100, k, nil) },
+			"linear": func(k int) RawStations { return r.findLinear(49.9, -100, k, nil) },
+		} {
+			assert.Equal(t, []int{4, 5}, ids(find(1)), name)
+			assert.Equal(t, []int{4, 5}, ids(find(2)), name)
+			assert.Equal(t, []int{4, 5, 1, 3}, ids(find(3)), name)
+			assert.Equal(t, []int{4, 5, 1, 3, 2}, ids(find(10)), name)
+			for i := 0; i < 10; i++ {
+				assert.Equal(t, ids(find(3)), ids(find(3)), name)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		s := StationInventory.Find(50.4452, -104.6189, 1)
+		if assert.Len(t, s, 1) {
+			b, err := json.Marshal(s[0])
+			assert.NoError(t, err)
+			fields := map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal(b, &fields))
+			assert.Equal(t, s[0].SearchDistance(), fields["distance"])
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Empty(t, newStationIndex(nil).nearest(0, 0, 5, nil))
+		assert.Empty(t, StationInventory.Find(0, 0, 0))
+	})
+}
+
+func BenchmarkFind(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		StationInventory.Find(50.4452, -104.6189, 25)
+	}
+}
+
+func BenchmarkFindLinear(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		StationInventory.findLinear(50.4452, -104.6189, 25, nil)
+	}
+}
+
+func BenchmarkFindWithInterval(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		StationInventory.FindWithInterval(50.4452, -104.6189, 25, Hourly)
+	}
+}
+
+func BenchmarkFindWithIntervalLinear(b *testing.B) {
+	keep := func(a *StationMetadata) bool { return a.HasInterval(Hourly) }
+	for i := 0; i < b.N; i++ {
+		StationInventory.findLinear(50.4452, -104.6189, 25, keep)
+	}
+}
+
+func BenchmarkStationIndex(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newStationIndex(StationInventory)
+	}
+}
@@ -9,14 +9,18 @@ import (
 var (
 	//go:embed "station-inventory.json"
 	stationInventoryFS embed.FS
-	StationInventory   = RawStations{}
+	// StationInventory is the active station inventory, loaded from the embedded
+	// station-inventory.json at init and replaced by SetInventory
+	StationInventory = RawStations{}
 )
 
 func init() {
-	err := StationInventory.loadData()
+	s := RawStations{}
+	err := s.loadData()
 	if err != nil {
 		panic(fmt.Errorf("failed init: %s", err))
 	}
+	SetInventory(s)
 }
 
 func (r *RawStations) loadData() error {
@@ -0,0 +1,211 @@
+package weather_gc_ca
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// inventoryMu guards StationInventory and inventoryIndex while the inventory is swapped
+var inventoryMu sync.RWMutex
+
+// Inventory returns the active station inventory. The stations returned are never modified,
+// a search can keep using them while the inventory is replaced by SetInventory
+func Inventory() RawStations {
+	inventoryMu.RLock()
+	defer inventoryMu.RUnlock()
+	return StationInventory
+}
+
+// SetInventory replaces the active station inventory and rebuilds its index, it is safe to
+// call while the inventory is searched through Inventory and the handlers. The stations
+// must not be modified once set. StationInventory is replaced as well, code that reads the
+// variable directly must not run concurrently with SetInventory
+func SetInventory(r RawStations) {
+	idx := newStationIndex(r)
+
+	inventoryMu.Lock()
+	defer inventoryMu.Unlock()
+	StationInventory, inventoryIndex = r, idx
+}
+
+// activeIndex returns the index of the active inventory
+func activeIndex() *stationIndex {
+	inventoryMu.RLock()
+	defer inventoryMu.RUnlock()
+	return inventoryIndex
+}
+
+// LoadInventoryCSV reads the Station Inventory CSV published by Environment Canada from
+// a file, see ReadInventoryCSV
+func LoadInventoryCSV(path string) (RawStations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory: %s", err)
+	}
+	defer f.Close()
+
+	return ReadInventoryCSV(f)
+}
+
+// ReadInventoryCSV parses the Station Inventory CSV published by Environment Canada.
+// The lines before the header (modified date, disclaimer) are skipped and the columns are
+// found by their name, an empty number is 0 as in the embedded inventory
+func ReadInventoryCSV(r io.Reader) (RawStations, error) {
+	c := csv.NewReader(r)
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = true
+
+	var header map[string]int
+	for header == nil {
+		record, err := c.Read()
+		if err == io.EOF {
+			return nil, fmt.Errorf("failed to read inventory: header not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inventory: %s", err)
+		}
+		if len(record) > 0 && strings.TrimPrefix(strings.TrimSpace(record[0]), "\ufeff") == "Name" {
+			header = make(map[string]int, len(record))
+			for i, name := range record {
+				header[strings.TrimSpace(name)] = i
+			}
+		}
+	}
+	for _, name := range []string{"Name", "Station ID"} {
+		if _, ok := header[name]; !ok {
+			return nil, fmt.Errorf("failed to read inventory: missing column %q", name)
+		}
+	}
+
+	s := RawStations{}
+	for {
+		record, err := c.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inventory: %s", err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		a, err := inventoryRecord(header, record)
+		if err != nil {
+			line, _ := c.FieldPos(0)
+			return nil, fmt.Errorf("failed to read inventory line %d: %s", line, err)
+		}
+		s = append(s, a)
+	}
+	return s, nil
+}
+
+// inventoryRecord parses a station of the inventory CSV
+func inventoryRecord(header map[string]int, record []string) (a StationMetadata, err error) {
+	get := func(name string) string {
+		i, ok := header[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	// the first error is kept
+	number := func(name string) float64 {
+		v := get(name)
+		if v == "" || err != nil {
+			return 0
+		}
+		f, e := strconv.ParseFloat(v, 64)
+		if e != nil {
+			err = fmt.Errorf("failed to parse %s: %s", name, e)
+		}
+		return f
+	}
+	integer := func(name string) int {
+		return int(number(name))
+	}
+
+	a = StationMetadata{
+		Name:             get("Name"),
+		Province:         get("Province"),
+		ClimateID:        get("Climate ID"),
+		StationID:        integer("Station ID"),
+		WMOID:            get("WMO ID"),
+		TCID:             get("TC ID"),
+		Latitude:         number("Latitude (Decimal Degrees)"),
+		Longitude:        number("Longitude (Decimal Degrees)"),
+		Latitude_int:     int32(number("Latitude")),
+		Longitude_int:    int32(number("Longitude")),
+		Elevation:        number("Elevation (m)"),
+		FirstYear:        integer("First Year"),
+		LastYear:         integer("Last Year"),
+		HourlyFirstYear:  integer("HLY First Year"),
+		HourlyLastYear:   integer("HLY Last Year"),
+		DailyFirstYear:   integer("DLY First Year"),
+		DailyLastYear:    integer("DLY Last Year"),
+		MonthlyFirstYear: integer("MLY First Year"),
+		MonthlyLastYear:  integer("MLY Last Year"),
+	}
+	if err == nil && a.StationID == 0 {
+		err = fmt.Errorf("failed to parse Station ID: missing")
+	}
+	return a, err
+}
+
+// LoadInventoryJSON reads an inventory in the format of the embedded station-inventory.json
+// from a file, see ReadInventoryJSON
+func LoadInventoryJSON(path string) (RawStations, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory: %s", err)
+	}
+	defer f.Close()
+
+	return ReadInventoryJSON(f)
+}
+
+// ReadInventoryJSON parses an inventory in the format of the embedded station-inventory.json,
+// as written by InventoryJSON
+func ReadInventoryJSON(r io.Reader) (RawStations, error) {
+	s := RawStations{}
+	err := json.NewDecoder(r).Decode(&s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %s", err)
+	}
+	return s, nil
+}
+
+// LoadInventory reads an inventory from a file, a .csv file is the Station Inventory CSV
+// and any other file is in the format of the embedded station-inventory.json
+func LoadInventory(path string) (RawStations, error) {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return LoadInventoryCSV(path)
+	}
+	return LoadInventoryJSON(path)
+}
+
+// inventoryJSON is the encoding of a station in the embedded inventory, the columns of the CSV
+type inventoryJSON StationMetadata
+
+// InventoryJSON writes the stations in the format of the embedded station-inventory.json,
+// the inventory is regenerated from the CSV with ReadInventoryCSV and InventoryJSON
+func (r RawStations) InventoryJSON(w io.Writer) error {
+	a := make([]inventoryJSON, len(r))
+	for i := range r {
+		a[i] = inventoryJSON(r[i])
+	}
+
+	err := json.NewEncoder(w).Encode(a)
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package weather_gc_ca
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventoryCSV(t *testing.T) {
+	s, err := LoadInventoryCSV("_testdata/station-inventory.csv")
+	if !assert.NoError(t, err) || !assert.Len(t, s, 4) {
+		return
+	}
+
+	a := s[1]
+	assert.Equal(t, "TORONTO CITY CENTRE", a.Name)
+	assert.Equal(t, "ONTARIO", a.Province)
+	assert.Equal(t, "6158359", a.ClimateID)
+	assert.Equal(t, 30247, a.StationID)
+	assert.Equal(t, "71265", a.WMOID)
+	assert.Equal(t, "YTZ", a.TCID)
+	assert.Equal(t, 43.63, a.Latitude)
+	assert.Equal(t, -79.4, a.Longitude)
+	assert.Equal(t, int32(433739000), a.Latitude_int)
+	assert.Equal(t, 76.5, a.Elevation)
+	assert.Equal(t, 2009, a.HourlyFirstYear)
+	assert.Equal(t, 2010, a.DailyFirstYear)
+	assert.Equal(t, 0, a.MonthlyFirstYear)
+	assert.Equal(t, "MONTRÉAL INT'L A", s[2].Name)
+
+	// the regenerated json is read as the embedded inventory
+	b := bytes.Buffer{}
+	if assert.NoError(t, s.InventoryJSON(&b)) {
+		fields := []map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &fields))
+		assert.Equal(t, "6158359", fields[1]["Climate ID"])
+		assert.Equal(t, 43.63, fields[1]["Latitude (Decimal Degrees)"])
+
+		r := RawStations{}
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &r))
+		assert.Equal(t, s, r)
+	}
+
+	for name, data := range map[string]string{
+		"no header":      "\"Modified Date: 2022-01-24 23:30 UTC\"\n",
+		"no station id":  "\"Name\",\"Province\"\n\"A\",\"ONTARIO\"\n",
+		"invalid number": "\"Name\",\"Station ID\",\"Elevation (m)\"\n\"A\",\"1\",\"high\"\n",
+		"missing id":     "\"Name\",\"Station ID\"\n\"A\",\"\"\n",
+	} {
+		_, err := ReadInventoryCSV(strings.NewReader(data))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSetInventory(t *testing.T) {
+	original := Inventory()
+	defer SetInventory(original)
+
+	s, err := LoadInventoryCSV("_testdata/station-inventory.csv")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				r := Inventory()
+				found := r.Find(43.63, -79.4, 1)
+				assert.NotEmpty(t, found)
+				_, ok := r.Station(30247)
+				assert.True(t, ok)
+			}
+		}()
+	}
+	SetInventory(s)
+	wg.Wait()
+
+	assert.Len(t, Inventory(), 4)
+	assert.NotNil(t, Inventory().index(), "the new inventory is indexed")
+	assert.Nil(t, original.index(), "the previous inventory is searched linearly")
+	a, ok := Inventory().Station(51157)
+	if assert.True(t, ok) {
+		assert.Equal(t, "YUL", a.TCID)
+	}
+	assert.Len(t, Inventory().NameFuzzy("montreal", 0), 1)
+}
@@ -0,0 +1,128 @@
+package weather_gc_ca
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Identifier types of a station
+const (
+	IDTypeStation = "station"
+	IDTypeClimate = "climate"
+	IDTypeWMO     = "wmo"
+	IDTypeTC      = "tc"
+)
+
+// Station returns the station with the StationID, StationInventory is searched with its index
+func (r RawStations) Station(id int) (s StationMetadata, ok bool) {
+	if idx := r.index(); idx != nil {
+		i, ok := idx.stationIDs[id]
+		if !ok {
+			return s, false
+		}
+		return idx.stations[i], true
+	}
+
+	for _, a := range r {
+		if a.StationID == id {
+			return a, true
+		}
+	}
+	return s, false
+}
+
+// StationsByClimateID returns the stations with the Climate ID, ignoring case
+func (r RawStations) StationsByClimateID(id string) RawStations {
+	return r.stationsByID(id, func(idx *stationIndex) map[string][]int { return idx.climateIDs },
+		func(a *StationMetadata) string { return a.ClimateID })
+}
+
+// StationsByWMOID returns the stations with the WMO ID. The ID is kept by the stations
+// replacing each other at a site, the stations with the most recent data are first
+func (r RawStations) StationsByWMOID(id string) RawStations {
+	return r.stationsByID(id, func(idx *stationIndex) map[string][]int { return idx.wmoIDs },
+		func(a *StationMetadata) string { return a.WMOID })
+}
+
+// StationsByTCID returns the stations with the Transport Canada ID, ignoring case. The ID is
+// kept by the stations replacing each other at a site, the stations with the most recent data are first
+func (r RawStations) StationsByTCID(id string) RawStations {
+	return r.stationsByID(id, func(idx *stationIndex) map[string][]int { return idx.tcIDs },
+		func(a *StationMetadata) string { return a.TCID })
+}
+
+// Lookup returns the stations with an identifier of any type, the StationID is tried first,
+// then the Climate ID, WMO ID and TC ID. The type of the identifier found is returned with
+// the stations, empty if none was found
+func (r RawStations) Lookup(id string) (RawStations, string) {
+	id = strings.TrimSpace(id)
+	if n, err := strconv.Atoi(id); err == nil {
+		if a, ok := r.Station(n); ok {
+			return RawStations{a}, IDTypeStation
+		}
+	}
+	if s := r.StationsByClimateID(id); len(s) > 0 {
+		return s, IDTypeClimate
+	}
+	if s := r.StationsByWMOID(id); len(s) > 0 {
+		return s, IDTypeWMO
+	}
+	if s := r.StationsByTCID(id); len(s) > 0 {
+		return s, IDTypeTC
+	}
+	return nil, ""
+}
+
+// LookupType returns the stations with the identifier of the type, see the IDType constants
+func (r RawStations) LookupType(idType, id string) RawStations {
+	switch idType {
+	case IDTypeStation:
+		n, err := strconv.Atoi(strings.TrimSpace(id))
+		if err != nil {
+			return nil
+		}
+		if a, ok := r.Station(n); ok {
+			return RawStations{a}
+		}
+	case IDTypeClimate:
+		return r.StationsByClimateID(id)
+	case IDTypeWMO:
+		return r.StationsByWMOID(id)
+	case IDTypeTC:
+		return r.StationsByTCID(id)
+	}
+	return nil
+}
+
+func (r RawStations) stationsByID(id string, indexed func(idx *stationIndex) map[string][]int, field func(a *StationMetadata) string) (s RawStations) {
+	key := normalizeID(id)
+	if key == "" {
+		return nil
+	}
+
+	if idx := r.index(); idx != nil {
+		for _, i := range indexed(idx)[key] {
+			s = append(s, idx.stations[i])
+		}
+	} else {
+		for i := range r {
+			if normalizeID(field(&r[i])) == key {
+				s = append(s, r[i])
+			}
+		}
+	}
+
+	sort.SliceStable(s, func(i, j int) bool {
+		if s[i].LastYear == s[j].LastYear {
+			return s[i].StationID < s[j].StationID
+		}
+		return s[i].LastYear > s[j].LastYear
+	})
+	return s
+}
+
+// normalizeID returns the identifier used as a key of the index
+func normalizeID(id string) string {
+	return strings.ToUpper(strings.TrimSpace(id))
+}
@@ -0,0 +1,67 @@
+package weather_gc_ca
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	linear := append(RawStations{}, StationInventory...)
+	assert.Nil(t, linear.index())
+
+	for name, r := range map[string]RawStations{"index": StationInventory, "linear": linear} {
+		t.Run(name, func(t *testing.T) {
+			s, ok := r.Station(30247)
+			if assert.True(t, ok) {
+				assert.Equal(t, "TORONTO CITY CENTRE", s.Name)
+			}
+			_, ok = r.Station(-1)
+			assert.False(t, ok)
+
+			for _, found := range []RawStations{
+				r.StationsByClimateID("6158359"),
+				r.StationsByWMOID("71265"),
+				r.StationsByTCID("ytz"),
+				r.LookupType(IDTypeTC, " YTZ "),
+			} {
+				if assert.NotEmpty(t, found) {
+					assert.Contains(t, stationIDs(found), 30247)
+				}
+			}
+			assert.Empty(t, r.StationsByTCID(""))
+			assert.Empty(t, r.LookupType(IDTypeStation, "a"))
+			assert.Empty(t, r.LookupType("iata", "YTZ"))
+
+			for id, idType := range map[string]string{
+				"30247":   IDTypeStation,
+				"6158359": IDTypeClimate,
+				"YTZ":     IDTypeTC,
+			} {
+				found, foundType := r.Lookup(id)
+				assert.Equal(t, idType, foundType, id)
+				assert.Contains(t, stationIDs(found), 30247, id)
+			}
+
+			found, foundType := r.Lookup("unknown")
+			assert.Empty(t, found)
+			assert.Equal(t, "", foundType)
+		})
+	}
+
+	t.Run("most recent first", func(t *testing.T) {
+		r := RawStations{
+			{StationID: 1, TCID: "YXX", LastYear: 1990},
+			{StationID: 2, TCID: "YXX", LastYear: 2020},
+			{StationID: 3, TCID: "yxx", LastYear: 2020},
+		}
+		assert.Equal(t, []int{2, 3, 1}, stationIDs(r.StationsByTCID("YXX")))
+	})
+}
+
+func stationIDs(s RawStations) (ids []int) {
+	for _, a := range s {
+		ids = append(ids, a.StationID)
+	}
+	return ids
+}
@@ -0,0 +1,470 @@
+package weather_gc_ca
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Flag symbols published in the bulk data legend
+const (
+	FlagAccumulated          = "A"
+	FlagOccurrenceEstimated  = "B"
+	FlagUncertain            = "C"
+	FlagEstimated            = "E"
+	FlagAccumulatedEstimated = "F"
+	FlagMayNotHaveOccurred   = "L"
+	FlagMissing              = "M"
+	FlagAboveZero            = "N"
+	FlagNotAvailable         = "NA"
+	FlagMoreThanOne          = "S"
+	FlagTrace                = "T"
+	FlagBelowZero            = "Y"
+	FlagIncomplete           = "^"
+	FlagNotReviewed          = "†"
+)
+
+// EstimatedFlags are the flags of values that were estimated rather than observed
+var EstimatedFlags = []string{FlagEstimated, FlagOccurrenceEstimated, FlagAccumulatedEstimated}
+
+// DefaultLegend is used to resolve flags when the legend of the document is not available,
+// eg. when decoding JSON
+var DefaultLegend = []FlagsXML{
+	{Symbol: FlagAccumulated, Description: "Accumulated"},
+	{Symbol: FlagOccurrenceEstimated, Description: "More than one occurrence and estimated"},
+	{Symbol: FlagUncertain, Description: "Precipitation occurred, amount uncertain"},
+	{Symbol: FlagEstimated, Description: "Estimated"},
+	{Symbol: FlagAccumulatedEstimated, Description: "Accumulated and estimated"},
+	{Symbol: FlagMayNotHaveOccurred, Description: "Precipitation may or may not have occurred"},
+	{Symbol: FlagMissing, Description: "Missing"},
+	{Symbol: FlagAboveZero, Description: "Temperature missing but known to be > 0"},
+	{Symbol: FlagNotAvailable, Description: "Not Available"},
+	{Symbol: FlagMoreThanOne, Description: "More than one occurrence"},
+	{Symbol: FlagTrace, Description: "Trace"},
+	{Symbol: FlagBelowZero, Description: "Temperature missing but known to be < 0"},
+	{Symbol: FlagIncomplete, Description: "The value displayed is based on incomplete data"},
+	{Symbol: FlagNotReviewed, Description: "Data that is not subject to review by the National Climate Archives"},
+}
+
+// Measurement is a numeric value of the bulk data and the quality flag published with it.
+// An empty element has no data and is not Valid, which distinguishes it from a value of 0,
+// the zero Measurement has no data
+type Measurement struct {
+	Value float64
+	Valid bool      // false if there is no data
+	Flag  *FlagsXML // nil if the value is not flagged
+}
+
+// NewMeasurement returns a valid measurement of the value without a flag
+func NewMeasurement(v float64) Measurement {
+	return Measurement{Value: v, Valid: true}
+}
+
+// Get returns the value and whether there is data
+func (m Measurement) Get() (float64, bool) {
+	return m.Value, m.Valid
+}
+
+// Symbol returns the flag symbol of the measurement, empty if not flagged
+func (m Measurement) Symbol() string {
+	if m.Flag == nil {
+		return ""
+	}
+	return m.Flag.Symbol
+}
+
+// Flagged reports whether the measurement is flagged with any of the symbols
+func (m Measurement) Flagged(symbols ...string) bool {
+	s := m.Symbol()
+	if s == "" {
+		return false
+	}
+	for _, a := range symbols {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Measurement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = Measurement{}
+	for _, a := range start.Attr {
+		if a.Name.Local == "flag" && a.Value != "" {
+			m.Flag = &FlagsXML{Symbol: a.Value}
+		}
+	}
+
+	s := ""
+	err := d.DecodeElement(&s, &start)
+	if err != nil {
+		return err
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+
+	m.Value, err = strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", start.Name.Local, err)
+	}
+	m.Valid = true
+	return nil
+}
+
+// MarshalJSON encodes the value only, null if there is no data. The flag is written as a
+// companion field of the record
+func (m Measurement) MarshalJSON() ([]byte, error) {
+	if !m.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(m.Value)
+}
+
+func (m *Measurement) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		m.Value, m.Valid = 0, false
+		return nil
+	}
+
+	err := json.Unmarshal(b, &m.Value)
+	if err != nil {
+		return err
+	}
+	m.Valid = true
+	return nil
+}
+
+// csv returns the value and flag columns, the value is empty if there is no data
+func (m Measurement) csv() []string {
+	v := ""
+	if m.Valid {
+		v = fmt.Sprintf("%.2f", m.Value)
+	}
+	return []string{v, m.Symbol()}
+}
+
+// Comparator qualifies the value of a BoundedMeasurement
+type Comparator int
+
+const (
+	Exact       Comparator = iota // the value was observed
+	LessThan                      // the value is below the bound, eg. "<31"
+	GreaterThan                   // the value is above the bound, eg. ">100"
+)
+
+// String returns the prefix of the comparator in the bulk data, empty for Exact
+func (c Comparator) String() string {
+	switch c {
+	case LessThan:
+		return "<"
+	case GreaterThan:
+		return ">"
+	}
+	return ""
+}
+
+// comparatorString returns the Comparator of the prefix, Exact if the prefix is unknown
+func comparatorString(s string) Comparator {
+	switch s {
+	case "<":
+		return LessThan
+	case ">":
+		return GreaterThan
+	}
+	return Exact
+}
+
+// BoundedMeasurement is a Measurement that may be published as a bound rather than
+// an observed value, the gust speeds below the reporting threshold are published as "<31".
+// Value holds the bound, so Get projects a bounded value onto its bound
+type BoundedMeasurement struct {
+	Measurement
+	Comparator Comparator
+}
+
+// ParseBoundedMeasurement parses a value of the bulk data or of the CSV output,
+// eg. "<31", ">100" or "42", an empty string has no data
+func ParseBoundedMeasurement(s string) (BoundedMeasurement, error) {
+	b := BoundedMeasurement{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return b, nil
+	}
+
+	if c := comparatorString(s[:1]); c != Exact {
+		b.Comparator = c
+		s = strings.TrimSpace(s[1:])
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return BoundedMeasurement{}, err
+	}
+	b.Value, b.Valid = v, true
+	return b, nil
+}
+
+// Bounded reports whether the value is a bound rather than an observed value
+func (b BoundedMeasurement) Bounded() bool {
+	return b.Valid && b.Comparator != Exact
+}
+
+// Exact returns the value and true only if there is data that was observed,
+// bounded values return false
+func (b BoundedMeasurement) Exact() (float64, bool) {
+	return b.Value, b.Valid && b.Comparator == Exact
+}
+
+// String returns the value as published, eg. "<31", empty if there is no data
+func (b BoundedMeasurement) String() string {
+	if !b.Valid {
+		return ""
+	}
+	return b.Comparator.String() + strconv.FormatFloat(b.Value, 'f', -1, 64)
+}
+
+func (b *BoundedMeasurement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*b = BoundedMeasurement{}
+	for _, a := range start.Attr {
+		if a.Name.Local == "flag" && a.Value != "" {
+			b.Flag = &FlagsXML{Symbol: a.Value}
+		}
+	}
+
+	s := ""
+	err := d.DecodeElement(&s, &start)
+	if err != nil {
+		return err
+	}
+
+	flag := b.Flag
+	*b, err = ParseBoundedMeasurement(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", start.Name.Local, err)
+	}
+	b.Flag = flag
+	return nil
+}
+
+// MarshalJSON encodes the value as a number, null if there is no data. The comparator
+// and the flag are written as companion fields of the record
+func (b BoundedMeasurement) MarshalJSON() ([]byte, error) {
+	return b.Measurement.MarshalJSON()
+}
+
+func (b *BoundedMeasurement) UnmarshalJSON(data []byte) error {
+	b.Comparator = Exact
+	return b.Measurement.UnmarshalJSON(data)
+}
+
+// csv returns the value including its comparator and the flag column
+func (b BoundedMeasurement) csv() []string {
+	v := ""
+	if b.Valid {
+		v = fmt.Sprintf("%s%.2f", b.Comparator, b.Value)
+	}
+	return []string{v, b.Symbol()}
+}
+
+var (
+	measurementType        = reflect.TypeOf(Measurement{})
+	boundedMeasurementType = reflect.TypeOf(BoundedMeasurement{})
+)
+
+// eachMeasurement calls f with every Measurement field of the records in data, including
+// the Measurement of each BoundedMeasurement,
+// data must be a pointer to a slice of records
+func eachMeasurement(data interface{}, f func(m *Measurement)) {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return
+	}
+
+	v = v.Elem()
+	for i := 0; i < v.Len(); i++ {
+		rec := v.Index(i)
+		for j := 0; j < rec.NumField(); j++ {
+			switch rec.Field(j).Type() {
+			case measurementType:
+				f(rec.Field(j).Addr().Interface().(*Measurement))
+			case boundedMeasurementType:
+				f(&rec.Field(j).Addr().Interface().(*BoundedMeasurement).Measurement)
+			}
+		}
+	}
+}
+
+// resolveFlags replaces the flag of each measurement with its entry in the legend,
+// flags not in the legend are kept with an empty description
+func resolveFlags(data interface{}, legend []FlagsXML) {
+	l := make(map[string]*FlagsXML, len(legend))
+	for i := range legend {
+		l[legend[i].Symbol] = &legend[i]
+	}
+
+	eachMeasurement(data, func(m *Measurement) {
+		if m.Flag == nil {
+			return
+		}
+		if f, ok := l[m.Flag.Symbol]; ok {
+			m.Flag = f
+		}
+	})
+}
+
+// lookupFlag returns the entry of DefaultLegend for the symbol, nil if the symbol is empty
+func lookupFlag(symbol string) *FlagsXML {
+	if symbol == "" {
+		return nil
+	}
+	for i := range DefaultLegend {
+		if DefaultLegend[i].Symbol == symbol {
+			return &DefaultLegend[i]
+		}
+	}
+	return &FlagsXML{Symbol: symbol}
+}
+
+// excludeFlags clears each measurement flagged with any of the symbols, leaving no data
+func excludeFlags(data interface{}, symbols []string) {
+	eachMeasurement(data, func(m *Measurement) {
+		if m.Flagged(symbols...) {
+			*m = Measurement{}
+		}
+	})
+}
+
+// jsonName returns the name of the field in the JSON encoding, empty if it is not encoded
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := strings.Split(f.Tag.Get("json"), ",")[0]
+	if tag == "-" {
+		return ""
+	}
+	if tag == "" {
+		return f.Name
+	}
+	return tag
+}
+
+// marshalRecord encodes the fields of the record in order, each flagged Measurement
+// is followed by a companion "{name}Flag" field holding the flag symbol and each bounded
+// BoundedMeasurement by a "{name}Bound" field holding the comparator, eg. "<"
+func marshalRecord(rec interface{}) ([]byte, error) {
+	v := reflect.ValueOf(rec)
+	t := v.Type()
+
+	b := &bytes.Buffer{}
+	b.WriteByte('{')
+	first := true
+	write := func(name string, value interface{}) error {
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+
+		k, _ := json.Marshal(name)
+		a, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(a)
+		return nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+
+		err := write(name, v.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := v.Field(i).Interface().(Measurement)
+		if bm, bounded := v.Field(i).Interface().(BoundedMeasurement); bounded {
+			m, ok = bm.Measurement, true
+			if bm.Bounded() {
+				err = write(name+"Bound", bm.Comparator.String())
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if ok && m.Flag != nil {
+			err = write(name+"Flag", m.Flag.Symbol)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// unmarshalRecord decodes the JSON written by marshalRecord into the record pointer,
+// the flags are resolved against DefaultLegend
+func unmarshalRecord(b []byte, rec interface{}) error {
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(b, &fields)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(rec).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+
+		if raw, ok := fields[name]; ok {
+			err = json.Unmarshal(raw, v.Field(i).Addr().Interface())
+			if err != nil {
+				return fmt.Errorf("invalid %s: %s", name, err)
+			}
+		}
+
+		m, ok := v.Field(i).Addr().Interface().(*Measurement)
+		if bm, bounded := v.Field(i).Addr().Interface().(*BoundedMeasurement); bounded {
+			m, ok = &bm.Measurement, true
+			if raw, found := fields[name+"Bound"]; found {
+				c := ""
+				err = json.Unmarshal(raw, &c)
+				if err != nil {
+					return fmt.Errorf("invalid %sBound: %s", name, err)
+				}
+				bm.Comparator = comparatorString(c)
+			}
+		}
+
+		if ok {
+			symbol := ""
+			if raw, ok := fields[name+"Flag"]; ok {
+				err = json.Unmarshal(raw, &symbol)
+				if err != nil {
+					return fmt.Errorf("invalid %sFlag: %s", name, err)
+				}
+			}
+			m.Flag = lookupFlag(symbol)
+		}
+	}
+
+	return nil
+}
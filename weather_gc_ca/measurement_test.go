@@ -0,0 +1,223 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeTestData(t *testing.T, file string, data StationDataXML) *ClimateDataXML {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := &ClimateDataXML{Data: data}
+	err = xml.Unmarshal(b, x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func TestFlags(t *testing.T) {
+	d := &DailyDataXML{}
+	decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+
+	first := (*d)[0]
+	assert.Nil(t, first.MaxTemp.Flag)
+	assert.Equal(t, -0.5, first.MaxTemp.Value)
+	if assert.NotNil(t, first.TotalSnow.Flag) {
+		assert.Equal(t, FlagTrace, first.TotalSnow.Flag.Symbol)
+		assert.Equal(t, "Trace", first.TotalSnow.Flag.Description)
+	}
+	assert.True(t, first.TotalSnow.Flagged(FlagTrace, FlagMissing))
+	assert.False(t, first.TotalSnow.Flagged(EstimatedFlags...))
+
+	t.Run("unknown flag", func(t *testing.T) {
+		m := &MonthlyDataXML{}
+		decodeTestData(t, "./_testdata/test-monthly_toronto.xml", m)
+		found := false
+		for _, a := range *m {
+			if a.MeanTemp.Flagged("I") {
+				found = true
+				assert.Equal(t, "", a.MeanTemp.Flag.Description)
+			}
+		}
+		assert.True(t, found, "expected a value flagged I")
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rows := d.csv()
+		header := rows[0]
+		assert.Equal(t, "TotalSnowFlag", header[16])
+		assert.Equal(t, "T", rows[1][16])
+		assert.Equal(t, "", rows[1][4])
+		for _, r := range rows {
+			assert.Equal(t, len(header), len(r))
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(first)
+		assert.NoError(t, err)
+
+		fields := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(b, &fields))
+		assert.Equal(t, -0.5, fields["maxTemp"])
+		assert.Equal(t, "T", fields["snowfallFlag"])
+		assert.NotContains(t, fields, "maxTempFlag")
+
+		decoded := DailyBaseXML{}
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, first.TotalSnow.Value, decoded.TotalSnow.Value)
+		assert.Equal(t, *first.TotalSnow.Flag, *decoded.TotalSnow.Flag)
+		assert.Nil(t, decoded.MaxTemp.Flag)
+		assert.Equal(t, first.Year, decoded.Year)
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		flagged := 0
+		for _, a := range *d {
+			if a.TotalSnow.Flagged(FlagTrace, FlagMissing) {
+				flagged++
+			}
+		}
+		assert.Greater(t, flagged, 0)
+
+		e := d.Exclude(FlagTrace, FlagMissing).(*DailyDataXML)
+		assert.Equal(t, len(*d), len(*e))
+		for _, a := range *e {
+			assert.False(t, a.TotalSnow.Flagged(FlagTrace, FlagMissing))
+		}
+
+		// the original data is unchanged
+		assert.True(t, (*d)[0].TotalSnow.Flagged(FlagTrace))
+	})
+}
+
+func TestNullMeasurement(t *testing.T) {
+	d := &DailyDataXML{}
+	decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+
+	// <cooldegdays>0.0</cooldegdays> and <dirofmaxgust></dirofmaxgust>
+	first := (*d)[0]
+	v, ok := first.CoolDegDays.Get()
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, v)
+	_, ok = first.MaxGustDirection.Get()
+	assert.False(t, ok)
+
+	t.Run("csv", func(t *testing.T) {
+		rows := d.csv()
+		assert.Equal(t, "MaxGustDirection", rows[0][21])
+		assert.Equal(t, "", rows[1][21])
+		assert.Equal(t, "0.00", rows[1][11])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(first)
+		assert.NoError(t, err)
+
+		fields := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(b, &fields))
+		assert.Contains(t, fields, "windDirection")
+		assert.Nil(t, fields["windDirection"])
+		assert.Equal(t, 0.0, fields["coolDegDays"])
+
+		decoded := DailyBaseXML{}
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.False(t, decoded.MaxGustDirection.Valid)
+		assert.True(t, decoded.CoolDegDays.Valid)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		m := &MonthlyDataXML{}
+		decodeTestData(t, "./_testdata/test-monthly_toronto.xml", m)
+		// <meanmaxtemp flag="M"></meanmaxtemp>
+		a := (*m)[0]
+		assert.False(t, a.MeanMaxTemp.Valid)
+		assert.True(t, a.MeanMaxTemp.Flagged(FlagMissing))
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		e := d.Exclude(FlagTrace).(*DailyDataXML)
+		assert.False(t, (*e)[0].TotalSnow.Valid)
+		assert.True(t, (*d)[0].TotalSnow.Valid)
+	})
+
+	assert.Equal(t, Measurement{Value: 2.5, Valid: true}, NewMeasurement(2.5))
+	assert.False(t, Measurement{}.Valid)
+}
+
+func TestBoundedMeasurement(t *testing.T) {
+	d := &DailyDataXML{}
+	decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+
+	// <speedofmaxgust>&lt;31</speedofmaxgust>
+	first := (*d)[0]
+	assert.Equal(t, LessThan, first.MaxGustSpeed.Comparator)
+	assert.True(t, first.MaxGustSpeed.Bounded())
+	v, ok := first.MaxGustSpeed.Get()
+	assert.True(t, ok)
+	assert.Equal(t, 31.0, v)
+	_, ok = first.MaxGustSpeed.Exact()
+	assert.False(t, ok)
+	assert.Equal(t, "<31", first.MaxGustSpeed.String())
+
+	tests := []struct {
+		in   string
+		want BoundedMeasurement
+	}{
+		{"", BoundedMeasurement{}},
+		{"42", BoundedMeasurement{Measurement: NewMeasurement(42)}},
+		{"<31", BoundedMeasurement{Measurement: NewMeasurement(31), Comparator: LessThan}},
+		{"> 100.5", BoundedMeasurement{Measurement: NewMeasurement(100.5), Comparator: GreaterThan}},
+	}
+	for _, tt := range tests {
+		b, err := ParseBoundedMeasurement(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, b, tt.in)
+	}
+	_, err := ParseBoundedMeasurement("<")
+	assert.Error(t, err)
+
+	t.Run("csv", func(t *testing.T) {
+		rows := d.csv()
+		assert.Equal(t, "MaxGustSpeed", rows[0][23])
+		assert.Equal(t, "<31.00", rows[1][23])
+
+		b, err := ParseBoundedMeasurement(rows[1][23])
+		assert.NoError(t, err)
+		assert.Equal(t, first.MaxGustSpeed.Measurement.Value, b.Value)
+		assert.Equal(t, LessThan, b.Comparator)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(first)
+		assert.NoError(t, err)
+
+		fields := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(b, &fields))
+		assert.Equal(t, 31.0, fields["windGustSpeed"])
+		assert.Equal(t, "<", fields["windGustSpeedBound"])
+
+		decoded := DailyBaseXML{}
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, first.MaxGustSpeed, decoded.MaxGustSpeed)
+	})
+
+	t.Run("hourly", func(t *testing.T) {
+		h := &HourlyDataXML{}
+		decodeTestData(t, "./_testdata/test-hourly_toronto.xml", h)
+		v, ok := (*h)[0].WindSpeed.Exact()
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, v, 0.0)
+
+		b, err := json.Marshal((*h)[0])
+		assert.NoError(t, err)
+		assert.NotContains(t, string(b), "windSpeedBound")
+	})
+}
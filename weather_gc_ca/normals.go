@@ -0,0 +1,344 @@
+package weather_gc_ca
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The WMO 3/5 rule, a month of daily data only qualifies for the mean of an element when
+// no more than 5 days, and no more than 3 consecutive days, are missing. A total, or a
+// count of days, is biased low by any missing day and needs every day of the month
+const (
+	MaxMissingDays            = 5
+	MaxConsecutiveMissingDays = 3
+)
+
+// Daily elements of the normals, named as the columns of the daily CSV
+const (
+	ElementMaxTemp            = "MaxTemp"
+	ElementMinTemp            = "MinTemp"
+	ElementMeanTemp           = "MeanTemp"
+	ElementTotalRain          = "TotalRain"
+	ElementTotalSnow          = "TotalSnow"
+	ElementTotalPrecipitation = "TotalPrecipitation"
+)
+
+// normalElements are the daily elements of the normals in the order of the columns,
+// temperatures are averaged over a month and precipitation totalled over complete months
+var normalElements = []struct {
+	name  string
+	value func(d *DailyBaseXML) Measurement
+	total bool
+}{
+	{ElementMaxTemp, func(d *DailyBaseXML) Measurement { return d.MaxTemp }, false},
+	{ElementMinTemp, func(d *DailyBaseXML) Measurement { return d.MinTemp }, false},
+	{ElementMeanTemp, func(d *DailyBaseXML) Measurement { return d.MeanTemp }, false},
+	{ElementTotalRain, func(d *DailyBaseXML) Measurement { return d.TotalRain }, true},
+	{ElementTotalSnow, func(d *DailyBaseXML) Measurement { return d.TotalSnow }, true},
+	{ElementTotalPrecipitation, func(d *DailyBaseXML) Measurement { return d.TotalPrecipitation }, true},
+}
+
+// DayCount is the number of days of a month with a daily element at or above a threshold,
+// or at or below it
+type DayCount struct {
+	Element   string
+	Threshold float64
+	Below     bool
+}
+
+// String returns the name of the count, eg. MaxTemp>=30 or MinTemp<=0
+func (c DayCount) String() string {
+	op := ">="
+	if c.Below {
+		op = "<="
+	}
+	return fmt.Sprintf("%s%s%g", c.Element, op, c.Threshold)
+}
+
+func (c DayCount) count(v float64) bool {
+	if c.Below {
+		return v <= c.Threshold
+	}
+	return v >= c.Threshold
+}
+
+// DefaultDayCounts are the days counted by a ReferencePeriod unless set otherwise: the days
+// above freezing and of heat, the days of frost, and the days of precipitation and of snow
+var DefaultDayCounts = []DayCount{
+	{Element: ElementMaxTemp, Threshold: 0.1},
+	{Element: ElementMaxTemp, Threshold: 30},
+	{Element: ElementMinTemp, Threshold: 0, Below: true},
+	{Element: ElementTotalPrecipitation, Threshold: 0.2},
+	{Element: ElementTotalPrecipitation, Threshold: 10},
+	{Element: ElementTotalSnow, Threshold: 0.2},
+}
+
+// ReferencePeriod computes the monthly normals of daily data over a range of years, eg.
+// 1991-2020. The normal of an element is the average of its monthly values over the years
+// of the month that qualify, under the 3/5 rule for the temperatures and without a missing
+// day for the totals and the day counts, and it is only computed when at least
+// MinYears qualify, by default 80% of the years of the period as recommended by the WMO.
+// A normal computed from fewer years than the period is flagged FlagIncomplete
+type ReferencePeriod struct {
+	start, end int
+	minYears   int
+	dayCounts  []DayCount
+}
+
+// NewReferencePeriod returns the reference period of the years from start to end
+func NewReferencePeriod(start, end int) *ReferencePeriod {
+	return &ReferencePeriod{
+		start:     start,
+		end:       end,
+		minYears:  int(math.Ceil(0.8 * float64(end-start+1))),
+		dayCounts: DefaultDayCounts,
+	}
+}
+
+// MinYears sets the number of years of a month that must qualify for its normals
+func (p *ReferencePeriod) MinYears(years int) *ReferencePeriod {
+	p.minYears = years
+	return p
+}
+
+// DayCounts sets the days counted, see DefaultDayCounts
+func (p *ReferencePeriod) DayCounts(counts ...DayCount) *ReferencePeriod {
+	p.dayCounts = counts
+	return p
+}
+
+// MonthlyNormal is the normals of a month of the year. Temperatures are the average of the
+// monthly means in °C, rain, snow and precipitation the average of the monthly totals and
+// the day counts the average number of days of the month
+type MonthlyNormal struct {
+	Month              int              `json:"month"`
+	MeanMaxTemp        Measurement      `json:"maxTemp"`
+	MeanMinTemp        Measurement      `json:"minTemp"`
+	MeanTemp           Measurement      `json:"meanTemp"`
+	TotalRain          Measurement      `json:"rainfall"`
+	TotalSnow          Measurement      `json:"snowfall"`
+	TotalPrecipitation Measurement      `json:"totalPrecip"`
+	DayCounts          []DayCountNormal `json:"dayCounts"`
+}
+
+func (m MonthlyNormal) MarshalJSON() ([]byte, error) {
+	return marshalRecord(m)
+}
+
+func (m *MonthlyNormal) UnmarshalJSON(b []byte) error {
+	return unmarshalRecord(b, m)
+}
+
+// DayCountNormal is the average number of days of a month counted by a DayCount
+type DayCountNormal struct {
+	Element   string      `json:"element"`
+	Threshold float64     `json:"threshold"`
+	Below     bool        `json:"below"`
+	Days      Measurement `json:"days"`
+}
+
+// DayCount returns the days counted
+func (c DayCountNormal) DayCount() DayCount {
+	return DayCount{Element: c.Element, Threshold: c.Threshold, Below: c.Below}
+}
+
+func (c DayCountNormal) MarshalJSON() ([]byte, error) {
+	return marshalRecord(c)
+}
+
+func (c *DayCountNormal) UnmarshalJSON(b []byte) error {
+	return unmarshalRecord(b, c)
+}
+
+// MonthCompleteness is the missing days of an element in a month of the reference period
+// and whether the month qualifies for the normals of the element, see MaxMissingDays
+type MonthCompleteness struct {
+	Year        int    `json:"year"`
+	Month       int    `json:"month"`
+	Element     string `json:"element"`
+	Missing     int    `json:"missing"`
+	Consecutive int    `json:"consecutive"` // the longest run of missing days
+	Qualifies   bool   `json:"qualifies"`
+}
+
+// Normals is the monthly normals of a reference period, with the completeness of each
+// element in each month of each year of the period ordered by month, year and element
+type Normals struct {
+	Start        int                 `json:"start"`
+	End          int                 `json:"end"`
+	Months       []MonthlyNormal     `json:"months"`
+	Completeness []MonthCompleteness `json:"completeness"`
+}
+
+// Normals computes the monthly normals of the daily data, the days outside of the
+// period are ignored and a day without a record is missing
+func (p *ReferencePeriod) Normals(d *DailyDataXML) *Normals {
+	days := map[Timeframe]*DailyBaseXML{}
+	for i := range *d {
+		a := &(*d)[i]
+		if a.Year >= p.start && a.Year <= p.end {
+			days[Timeframe{Year: a.Year, Month: a.Month, Day: a.Day}] = a
+		}
+	}
+
+	n := &Normals{Start: p.start, End: p.end, Completeness: []MonthCompleteness{}}
+	for month := 1; month <= 12; month++ {
+		// the monthly values of each element and day count over the qualifying years
+		values := make(map[string]aggregate, len(normalElements))
+		counts := make([]aggregate, len(p.dayCounts))
+
+		for year := p.start; year <= p.end; year++ {
+			for _, e := range normalElements {
+				c := MonthCompleteness{Year: year, Month: month, Element: e.name}
+				daily := aggregate{}
+				run := 0
+				for day := 1; day <= daysIn(year, month); day++ {
+					var m Measurement
+					if a, ok := days[Timeframe{Year: year, Month: month, Day: day}]; ok {
+						m = e.value(a)
+					}
+					if !m.Valid {
+						c.Missing++
+						run++
+						if run > c.Consecutive {
+							c.Consecutive = run
+						}
+						continue
+					}
+					run = 0
+					daily = append(daily, m.Value)
+				}
+				c.Qualifies = c.Missing <= MaxMissingDays && c.Consecutive <= MaxConsecutiveMissingDays
+				if e.total {
+					c.Qualifies = c.Missing == 0
+				}
+				n.Completeness = append(n.Completeness, c)
+				if !c.Qualifies {
+					continue
+				}
+
+				if e.total {
+					values[e.name] = append(values[e.name], daily.sum())
+				} else {
+					values[e.name] = append(values[e.name], daily.mean())
+				}
+				for i, dc := range p.dayCounts {
+					// a missing day could have been counted
+					if dc.Element != e.name || c.Missing > 0 {
+						continue
+					}
+					count := 0
+					for _, v := range daily {
+						if dc.count(v) {
+							count++
+						}
+					}
+					counts[i] = append(counts[i], float64(count))
+				}
+			}
+		}
+
+		normal := MonthlyNormal{
+			Month:              month,
+			MeanMaxTemp:        p.normal(values[ElementMaxTemp]),
+			MeanMinTemp:        p.normal(values[ElementMinTemp]),
+			MeanTemp:           p.normal(values[ElementMeanTemp]),
+			TotalRain:          p.normal(values[ElementTotalRain]),
+			TotalSnow:          p.normal(values[ElementTotalSnow]),
+			TotalPrecipitation: p.normal(values[ElementTotalPrecipitation]),
+			DayCounts:          make([]DayCountNormal, len(p.dayCounts)),
+		}
+		for i, dc := range p.dayCounts {
+			normal.DayCounts[i] = DayCountNormal{
+				Element:   dc.Element,
+				Threshold: dc.Threshold,
+				Below:     dc.Below,
+				Days:      p.normal(counts[i]),
+			}
+		}
+		n.Months = append(n.Months, normal)
+	}
+	return n
+}
+
+// normal returns the average of the monthly values of the qualifying years
+func (p *ReferencePeriod) normal(values aggregate) Measurement {
+	if len(values) == 0 || len(values) < p.minYears {
+		return Measurement{}
+	}
+
+	m := NewMeasurement(values.mean())
+	if len(values) < p.end-p.start+1 {
+		m.Flag = lookupFlag(FlagIncomplete)
+	}
+	return m
+}
+
+// Qualifying returns the months of the element that qualify for the normals
+func (n *Normals) Qualifying(element string) (months []MonthCompleteness) {
+	for _, c := range n.Completeness {
+		if c.Element == element && c.Qualifies {
+			months = append(months, c)
+		}
+	}
+	return months
+}
+
+func (n *Normals) csv() [][]string {
+	header := []string{
+		"Month",
+		"MeanMaxTemp", "MeanMaxTempFlag",
+		"MeanMinTemp", "MeanMinTempFlag",
+		"MeanTemp", "MeanTempFlag",
+		"TotalRain", "TotalRainFlag",
+		"TotalSnow", "TotalSnowFlag",
+		"TotalPrecipitation", "TotalPrecipitationFlag",
+	}
+	if len(n.Months) > 0 {
+		for _, c := range n.Months[0].DayCounts {
+			name := "Days" + c.DayCount().String()
+			header = append(header, name, name+"Flag")
+		}
+	}
+
+	s := [][]string{header}
+	for _, a := range n.Months {
+		row := []string{
+			fmt.Sprintf("%d", a.Month),
+		}
+		row = append(row, a.MeanMaxTemp.csv()...)
+		row = append(row, a.MeanMinTemp.csv()...)
+		row = append(row, a.MeanTemp.csv()...)
+		row = append(row, a.TotalRain.csv()...)
+		row = append(row, a.TotalSnow.csv()...)
+		row = append(row, a.TotalPrecipitation.csv()...)
+		for _, c := range a.DayCounts {
+			row = append(row, c.Days.csv()...)
+		}
+		s = append(s, row)
+	}
+	return s
+}
+
+// CSV writes the monthly normals, a row for each month
+func (n *Normals) CSV(w io.Writer) error {
+	return csv.NewWriter(w).WriteAll(n.csv())
+}
+
+// CompletenessCSV writes the completeness of each element in each month of the period
+func (n *Normals) CompletenessCSV(w io.Writer) error {
+	s := [][]string{{"Year", "Month", "Element", "Missing", "Consecutive", "Qualifies"}}
+	for _, c := range n.Completeness {
+		s = append(s, []string{
+			fmt.Sprintf("%d", c.Year),
+			fmt.Sprintf("%d", c.Month),
+			c.Element,
+			fmt.Sprintf("%d", c.Missing),
+			fmt.Sprintf("%d", c.Consecutive),
+			fmt.Sprintf("%t", c.Qualifies),
+		})
+	}
+	return csv.NewWriter(w).WriteAll(s)
+}
@@ -0,0 +1,157 @@
+package weather_gc_ca
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormals(t *testing.T) {
+	d := &DailyDataXML{}
+	decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+	published := &MonthlyDataXML{}
+	decodeTestData(t, "./_testdata/test-monthly_toronto.xml", published)
+
+	t.Run("single year", func(t *testing.T) {
+		n := NewReferencePeriod(1992, 1992).Normals(d)
+		if !assert.Len(t, n.Months, 12) {
+			return
+		}
+		// the normals of a single year are the monthly values published by ECCC
+		for _, a := range n.Months[:11] {
+			var b MonthlyBaseXML
+			for _, m := range *published {
+				if m.Year == 1992 && m.Month == a.Month {
+					b = m
+				}
+			}
+			assert.Equal(t, b.MeanMaxTemp.Value, a.MeanMaxTemp.Value, "month %d mean max", a.Month)
+			assert.Equal(t, b.MeanMinTemp.Value, a.MeanMinTemp.Value, "month %d mean min", a.Month)
+			assert.Equal(t, b.MeanTemp.Value, a.MeanTemp.Value, "month %d mean", a.Month)
+			assert.Equal(t, b.TotalRain.Value, a.TotalRain.Value, "month %d rain", a.Month)
+			assert.Equal(t, b.TotalSnow.Value, a.TotalSnow.Value, "month %d snow", a.Month)
+			assert.Equal(t, b.TotalPrecipitation.Value, a.TotalPrecipitation.Value, "month %d precipitation", a.Month)
+		}
+
+		jan := n.Months[0]
+		assert.Nil(t, jan.MeanTemp.Flag)
+		if assert.Len(t, jan.DayCounts, len(DefaultDayCounts)) {
+			assert.Equal(t, "MinTemp<=0", jan.DayCounts[2].DayCount().String())
+			assert.Equal(t, 27.0, jan.DayCounts[2].Days.Value)
+			assert.Equal(t, 12.0, jan.DayCounts[5].Days.Value)
+		}
+
+		// 5 days of december are missing, none consecutive
+		assert.True(t, n.Months[11].MeanTemp.Valid)
+		assert.False(t, n.Months[11].TotalPrecipitation.Valid)
+		assert.False(t, n.Months[11].DayCounts[2].Days.Valid)
+		assert.Len(t, n.Qualifying(ElementMeanTemp), 12)
+		assert.Len(t, n.Qualifying(ElementTotalPrecipitation), 11)
+		assert.Len(t, n.Completeness, 12*6)
+	})
+
+	t.Run("3/5 rule", func(t *testing.T) {
+		partial := DailyDataXML{}
+		for _, a := range *d {
+			switch {
+			case a.Month == 1 && a.Day >= 10 && a.Day <= 13:
+				// 4 consecutive days
+				continue
+			case a.Month == 12 && a.Day == 1:
+				// a 6th day
+				continue
+			}
+			partial = append(partial, a)
+		}
+
+		n := NewReferencePeriod(1992, 1992).Normals(&partial)
+		assert.False(t, n.Months[0].MeanTemp.Valid)
+		assert.False(t, n.Months[11].MeanTemp.Valid)
+		assert.False(t, n.Months[0].DayCounts[0].Days.Valid)
+		assert.True(t, n.Months[1].MeanTemp.Valid)
+		assert.Len(t, n.Qualifying(ElementMeanTemp), 10)
+
+		jan := n.Completeness[0]
+		assert.Equal(t, MonthCompleteness{Year: 1992, Month: 1, Element: ElementMaxTemp, Missing: 4, Consecutive: 4}, jan)
+	})
+
+	t.Run("incomplete totals", func(t *testing.T) {
+		partial := DailyDataXML{}
+		for _, a := range *d {
+			if a.Month == 3 && a.Day >= 10 && a.Day <= 12 {
+				// 3 days of march without precipitation, the temperatures are kept
+				a.TotalRain, a.TotalSnow, a.TotalPrecipitation = Measurement{}, Measurement{}, Measurement{}
+			}
+			partial = append(partial, a)
+		}
+
+		n := NewReferencePeriod(1992, 1992).Normals(&partial)
+		march := n.Months[2]
+		assert.True(t, march.MeanTemp.Valid)
+		assert.True(t, march.DayCounts[2].Days.Valid)
+		assert.False(t, march.TotalRain.Valid)
+		assert.False(t, march.TotalSnow.Valid)
+		assert.False(t, march.TotalPrecipitation.Valid)
+		assert.False(t, march.DayCounts[3].Days.Valid)
+		assert.False(t, march.DayCounts[5].Days.Valid)
+		assert.Len(t, n.Qualifying(ElementTotalPrecipitation), 10)
+
+		c := n.Completeness[2*6+5]
+		assert.Equal(t, MonthCompleteness{Year: 1992, Month: 3, Element: ElementTotalPrecipitation, Missing: 3, Consecutive: 3}, c)
+	})
+
+	t.Run("min years", func(t *testing.T) {
+		// 1993 has no data
+		n := NewReferencePeriod(1992, 1993).Normals(d)
+		assert.False(t, n.Months[0].MeanTemp.Valid)
+
+		n = NewReferencePeriod(1992, 1993).MinYears(1).Normals(d)
+		assert.Equal(t, -4.2, n.Months[0].MeanTemp.Value)
+		assert.True(t, n.Months[0].MeanTemp.Flagged(FlagIncomplete))
+		assert.Len(t, n.Completeness, 2*12*6)
+	})
+
+	t.Run("day counts", func(t *testing.T) {
+		n := NewReferencePeriod(1992, 1992).DayCounts(DayCount{Element: ElementMaxTemp, Threshold: 25}).Normals(d)
+		if assert.Len(t, n.Months[6].DayCounts, 1) {
+			assert.Equal(t, "MaxTemp>=25", n.Months[6].DayCounts[0].DayCount().String())
+			assert.True(t, n.Months[6].DayCounts[0].Days.Value > 0)
+			assert.Equal(t, 0.0, n.Months[0].DayCounts[0].Days.Value)
+		}
+	})
+
+	t.Run("writers", func(t *testing.T) {
+		n := NewReferencePeriod(1992, 1993).MinYears(1).Normals(d)
+
+		b := &bytes.Buffer{}
+		if !assert.NoError(t, n.CSV(b)) {
+			return
+		}
+		rows, err := csv.NewReader(b).ReadAll()
+		if assert.NoError(t, err) && assert.Len(t, rows, 13) {
+			assert.Equal(t, 1+6*2+len(DefaultDayCounts)*2, len(rows[0]))
+			assert.Equal(t, "DaysMaxTemp>=30", rows[0][15])
+			assert.Equal(t, []string{"1", "-0.80", "^"}, rows[1][:3])
+		}
+
+		b.Reset()
+		if assert.NoError(t, n.CompletenessCSV(b)) {
+			rows, err := csv.NewReader(b).ReadAll()
+			if assert.NoError(t, err) && assert.Len(t, rows, 1+2*12*6) {
+				assert.Equal(t, []string{"1993", "1", "MaxTemp", "31", "31", "false"}, rows[1+6])
+			}
+		}
+
+		j, err := json.Marshal(n)
+		if !assert.NoError(t, err) {
+			return
+		}
+		decoded := &Normals{}
+		if assert.NoError(t, json.Unmarshal(j, decoded)) {
+			assert.Equal(t, n, decoded)
+		}
+	})
+}
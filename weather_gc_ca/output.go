@@ -0,0 +1,98 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Formats the stations are written in by Write
+const (
+	FormatCSV     = "csv"
+	FormatJSON    = "json"
+	FormatGeoJSON = "geojson"
+	FormatNDJSON  = "ndjson"
+)
+
+// ContentType returns the media type of a format written by Write, empty for an unknown format
+func ContentType(format string) string {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSON:
+		return "application/json"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return ""
+}
+
+// Write writes the stations in the format: csv, json, geojson or ndjson
+func (r RawStations) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return r.CSV(w)
+	case FormatJSON:
+		err := json.NewEncoder(w).Encode(r)
+		if err != nil {
+			return fmt.Errorf("failed to write stations: %s", err)
+		}
+		return nil
+	case FormatGeoJSON:
+		return r.GeoJSON(w)
+	case FormatNDJSON:
+		return r.NDJSON(w)
+	}
+	return fmt.Errorf("invalid format: %s", format)
+}
+
+// geoJSONFeature is a station as a GeoJSON Feature
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         int                    `json:"id"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// GeoJSON writes the stations as a GeoJSON FeatureCollection of points identified by their
+// StationID, the properties of each feature are the metadata of the station as in its JSON
+func (r RawStations) GeoJSON(w io.Writer) error {
+	features := make([]geoJSONFeature, len(r))
+	for i, a := range r {
+		features[i] = geoJSONFeature{
+			Type:       "Feature",
+			ID:         a.StationID,
+			Geometry:   geoJSONPoint{Type: "Point", Coordinates: [2]float64{a.Longitude, a.Latitude}},
+			Properties: a.properties(),
+		}
+	}
+
+	err := json.NewEncoder(w).Encode(struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{"FeatureCollection", features})
+	if err != nil {
+		return fmt.Errorf("failed to write geojson: %s", err)
+	}
+	return nil
+}
+
+// NDJSON writes the stations as newline delimited JSON, a station per line
+func (r RawStations) NDJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, a := range r {
+		err := enc.Encode(a)
+		if err != nil {
+			return fmt.Errorf("failed to write ndjson: %s", err)
+		}
+	}
+	return nil
+}
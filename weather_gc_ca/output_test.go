@@ -0,0 +1,89 @@
+package weather_gc_ca
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationsOutput(t *testing.T) {
+	s := StationInventory.Find(50.4452, -104.6189, 3)
+
+	t.Run("geojson", func(t *testing.T) {
+		b := bytes.Buffer{}
+		if !assert.NoError(t, s.GeoJSON(&b)) {
+			return
+		}
+
+		fc := struct {
+			Type     string
+			Features []struct {
+				Type     string
+				ID       int
+				Geometry struct {
+					Type        string
+					Coordinates []float64
+				}
+				Properties map[string]interface{}
+			}
+		}{}
+		assert.NoError(t, json.Unmarshal(b.Bytes(), &fc))
+		assert.Equal(t, "FeatureCollection", fc.Type)
+		if assert.Len(t, fc.Features, len(s)) {
+			f := fc.Features[0]
+			assert.Equal(t, "Feature", f.Type)
+			assert.Equal(t, s[0].StationID, f.ID)
+			assert.Equal(t, "Point", f.Geometry.Type)
+			assert.Equal(t, []float64{s[0].Longitude, s[0].Latitude}, f.Geometry.Coordinates)
+			assert.Equal(t, s[0].Name, f.Properties["name"])
+			assert.Equal(t, s[0].ClimateID, f.Properties["climateID"])
+			assert.Equal(t, s[0].SearchDistance(), f.Properties["distance"])
+		}
+
+		b.Reset()
+		assert.NoError(t, RawStations{}.GeoJSON(&b))
+		assert.JSONEq(t, `{"type": "FeatureCollection", "features": []}`, b.String())
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		b := bytes.Buffer{}
+		if !assert.NoError(t, s.NDJSON(&b)) {
+			return
+		}
+
+		lines := 0
+		scanner := bufio.NewScanner(&b)
+		for scanner.Scan() {
+			fields := map[string]interface{}{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &fields))
+			assert.Equal(t, float64(s[lines].StationID), fields["stationID"])
+			lines++
+		}
+		assert.Equal(t, len(s), lines)
+	})
+
+	t.Run("formats", func(t *testing.T) {
+		for _, format := range []string{FormatCSV, FormatJSON, FormatGeoJSON, FormatNDJSON} {
+			assert.NotEmpty(t, ContentType(format))
+			assert.NoError(t, s.Write(&bytes.Buffer{}, format))
+		}
+		assert.Empty(t, ContentType("table"))
+		assert.Error(t, s.Write(&bytes.Buffer{}, "table"))
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		for format, code := range map[string]int{"geojson": http.StatusOK, "ndjson": http.StatusOK, "csv": http.StatusOK, "xml": http.StatusBadRequest} {
+			req := httptest.NewRequest("GET", "/station/search/?lat=50.4452&lng=-104.6189&max=3&format="+format, nil)
+			w := httptest.NewRecorder()
+			SearchHandler(w, req)
+			if assert.Equal(t, code, w.Code, format) && code == http.StatusOK {
+				assert.Equal(t, ContentType(format), w.Header().Get("Content-Type"))
+			}
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Polygon is the rings of a GeoJSON Polygon, the first ring is the exterior and the others
+// are holes. The positions are [longitude, latitude]
+type Polygon [][][2]float64
+
+// MultiPolygon is a set of polygons, a point is inside if it is inside any of them
+type MultiPolygon []Polygon
+
+// geoJSON is the subset of a GeoJSON object needed to read polygons
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []geoJSON       `json:"geometries"`
+	Features    []geoJSON       `json:"features"`
+}
+
+// ParsePolygon reads a GeoJSON Polygon or MultiPolygon, a Feature, FeatureCollection or
+// GeometryCollection is accepted and the union of its polygons is returned
+func ParsePolygon(b []byte) (MultiPolygon, error) {
+	g := geoJSON{}
+	err := json.Unmarshal(b, &g)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse geojson: %s", err)
+	}
+
+	m, err := g.polygons()
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("failed to parse geojson: no polygon found")
+	}
+	return m, nil
+}
+
+func (g geoJSON) polygons() (m MultiPolygon, err error) {
+	switch g.Type {
+	case "Polygon":
+		p := Polygon{}
+		err = json.Unmarshal(g.Coordinates, &p)
+		m = MultiPolygon{p}
+	case "MultiPolygon":
+		err = json.Unmarshal(g.Coordinates, &m)
+	case "Feature":
+		if g.Geometry == nil {
+			return nil, nil
+		}
+		return g.Geometry.polygons()
+	case "FeatureCollection":
+		for _, f := range g.Features {
+			p, err := f.polygons()
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, p...)
+		}
+		return m, nil
+	case "GeometryCollection":
+		for _, a := range g.Geometries {
+			p, err := a.polygons()
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, p...)
+		}
+		return m, nil
+	case "Point", "MultiPoint", "LineString", "MultiLineString":
+		// geometries without an area contain no stations
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to parse geojson: unsupported type %q", g.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s coordinates: %s", g.Type, err)
+	}
+	for _, p := range m {
+		for _, ring := range p {
+			if len(ring) < 4 {
+				return nil, fmt.Errorf("failed to parse %s coordinates: a ring needs at least 4 positions", g.Type)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Contains reports whether the point is inside the exterior ring and outside every hole
+func (p Polygon) Contains(lat, lng float64) bool {
+	if len(p) == 0 || !inRing(p[0], lat, lng) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if inRing(hole, lat, lng) {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether the point is inside any of the polygons
+func (m MultiPolygon) Contains(lat, lng float64) bool {
+	for _, p := range m {
+		if p.Contains(lat, lng) {
+			return true
+		}
+	}
+	return false
+}
+
+// bounds returns the bounding box of the exterior rings
+func (m MultiPolygon) bounds() (minLat, minLng, maxLat, maxLng float64) {
+	minLat, minLng = math.Inf(1), math.Inf(1)
+	maxLat, maxLng = math.Inf(-1), math.Inf(-1)
+	for _, p := range m {
+		if len(p) == 0 {
+			continue
+		}
+		for _, pos := range p[0] {
+			minLng, maxLng = math.Min(minLng, pos[0]), math.Max(maxLng, pos[0])
+			minLat, maxLat = math.Min(minLat, pos[1]), math.Max(maxLat, pos[1])
+		}
+	}
+	return minLat, minLng, maxLat, maxLng
+}
+
+// inRing reports whether the point is inside the ring by counting the edges crossed by a
+// ray cast from the point, the ring is planar in degrees as in GeoJSON
+func inRing(ring [][2]float64, lat, lng float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) &&
+			lng < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// WithinPolygon returns the stations inside the polygons, in the order of the stations.
+// It can be combined with WithInterval
+func (r RawStations) WithinPolygon(m MultiPolygon) (s RawStations) {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return r.Query(NewStationQuery().Polygon(m))
+}
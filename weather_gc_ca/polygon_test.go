@@ -0,0 +1,105 @@
+package weather_gc_ca
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a 10x10 degree square with a 2x2 hole in the middle
+const testPolygon = `{"type": "Polygon", "coordinates": [
+	[[-110, 45], [-100, 45], [-100, 55], [-110, 55], [-110, 45]],
+	[[-106, 49], [-104, 49], [-104, 51], [-106, 51], [-106, 49]]
+]}`
+
+func TestPolygon(t *testing.T) {
+	m, err := ParsePolygon([]byte(testPolygon))
+	if !assert.NoError(t, err) || !assert.Len(t, m, 1) {
+		return
+	}
+
+	assert.True(t, m.Contains(47, -108))
+	assert.False(t, m.Contains(50, -105), "inside the hole")
+	assert.False(t, m.Contains(56, -105))
+	assert.False(t, m.Contains(50, -99))
+
+	t.Run("multipolygon", func(t *testing.T) {
+		m, err := ParsePolygon([]byte(`{"type": "MultiPolygon", "coordinates": [
+			[[[0, 0], [1, 0], [1, 1], [0, 1], [0, 0]]],
+			[[[10, 10], [11, 10], [11, 11], [10, 11], [10, 10]]]
+		]}`))
+		assert.NoError(t, err)
+		assert.True(t, m.Contains(0.5, 0.5))
+		assert.True(t, m.Contains(10.5, 10.5))
+		assert.False(t, m.Contains(5, 5))
+	})
+
+	t.Run("feature collection", func(t *testing.T) {
+		m, err := ParsePolygon([]byte(`{"type": "FeatureCollection", "features": [
+			{"type": "Feature", "properties": {"name": "a"}, "geometry": ` + testPolygon + `},
+			{"type": "Feature", "properties": {"name": "b"}, "geometry": {"type": "Point", "coordinates": [0, 0]}}
+		]}`))
+		assert.NoError(t, err)
+		assert.Len(t, m, 1)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, a := range []string{
+			`{`,
+			`{"type": "Point", "coordinates": [0, 0]}`,
+			`{"type": "Circle"}`,
+			`{"type": "Polygon", "coordinates": [[[0, 0], [1, 1], [0, 0]]]}`,
+			`{"type": "Polygon", "coordinates": "a"}`,
+		} {
+			_, err := ParsePolygon([]byte(a))
+			assert.Error(t, err, a)
+		}
+	})
+
+	t.Run("stations", func(t *testing.T) {
+		s := StationInventory.WithinPolygon(m)
+		assert.NotEmpty(t, s)
+
+		// the ray casting of stations on an edge depends on the edge, they are left out
+		onEdge := func(a StationMetadata) bool {
+			for _, v := range []float64{45, 49, 51, 55} {
+				if a.Latitude == v {
+					return true
+				}
+			}
+			for _, v := range []float64{-110, -106, -104, -100} {
+				if a.Longitude == v {
+					return true
+				}
+			}
+			return false
+		}
+
+		count, found := 0, 0
+		for _, a := range s {
+			if !onEdge(a) {
+				found++
+			}
+		}
+		for _, a := range StationInventory {
+			if onEdge(a) {
+				continue
+			}
+			inSquare := a.Latitude > 45 && a.Latitude < 55 && a.Longitude > -110 && a.Longitude < -100
+			inHole := a.Latitude > 49 && a.Latitude < 51 && a.Longitude > -106 && a.Longitude < -104
+			if inSquare && !inHole {
+				count++
+			}
+		}
+		assert.Equal(t, count, found)
+
+		for _, a := range s {
+			// the stations near Regina are in the hole
+			assert.Greater(t, a.Distance(50.4452, -104.6189), 10.0)
+		}
+		for _, a := range s.WithInterval(Daily) {
+			assert.NotZero(t, a.DailyFirstYear)
+		}
+		assert.Empty(t, StationInventory.WithinPolygon(nil))
+	})
+}
@@ -0,0 +1,243 @@
+package weather_gc_ca
+
+import (
+	"strings"
+)
+
+// StationQuery is a search of the stations combining any of its predicates, built with the
+// chainable methods and run with RawStations.Query, eg. the daily stations in ALBERTA within
+// 50 km with data covering 1980-2010:
+//
+//	q := NewStationQuery().Interval(Daily).Province("ALBERTA").Within(lat, lng, 50).Covering(1980, 2010)
+//	s := StationInventory.Query(q)
+type StationQuery struct {
+	filters []func(a *StationMetadata) bool
+
+	near     bool
+	lat, lng float64
+	radius   float64 // km, 0 for no radius
+
+	interval   Interval
+	start, end int
+	years      int // the years of [start, end] covered, 0 for every year
+
+	fuzzy  string
+	byName bool // sorted by name unless sorted otherwise
+
+	sortBy *SortBy
+	limit  int
+	offset int
+}
+
+// NewStationQuery returns a query matching every station
+func NewStationQuery() *StationQuery {
+	return &StationQuery{}
+}
+
+// Filter adds a predicate the stations must match
+func (q *StationQuery) Filter(f func(a *StationMetadata) bool) *StationQuery {
+	q.filters = append(q.filters, f)
+	return q
+}
+
+// Province matches the stations of any of the provinces, ignoring case
+func (q *StationQuery) Province(provinces ...string) *StationQuery {
+	return q.Filter(func(a *StationMetadata) bool {
+		for _, p := range provinces {
+			if strings.EqualFold(strings.TrimSpace(p), a.Province) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// NameContains matches the stations with a name containing the query, ignoring case. The
+// results are sorted by name unless sorted otherwise
+func (q *StationQuery) NameContains(query string) *StationQuery {
+	q.byName = true
+	query = strings.ToLower(query)
+	return q.Filter(func(a *StationMetadata) bool {
+		return strings.Contains(strings.ToLower(a.Name), query)
+	})
+}
+
+// NameStartsWith matches the stations with a name starting with the query, ignoring case. The
+// results are sorted by name unless sorted otherwise
+func (q *StationQuery) NameStartsWith(query string) *StationQuery {
+	q.byName = true
+	query = strings.ToLower(query)
+	return q.Filter(func(a *StationMetadata) bool {
+		return strings.HasPrefix(strings.ToLower(a.Name), query)
+	})
+}
+
+// NameFuzzy matches the stations with a name relevant to the query, ignoring case, accents,
+// punctuation and typos (see FuzzyScore). The results are sorted most relevant first unless
+// sorted otherwise
+func (q *StationQuery) NameFuzzy(query string) *StationQuery {
+	q.fuzzy = query
+	return q.Filter(func(a *StationMetadata) bool {
+		return FuzzyScore(query, a.Name) >= MinFuzzyScore
+	})
+}
+
+// IDs matches the stations with any of the StationIDs
+func (q *StationQuery) IDs(ids ...int) *StationQuery {
+	m := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return q.Filter(func(a *StationMetadata) bool {
+		return m[a.StationID]
+	})
+}
+
+// Elevation matches the stations with an elevation between min and max meters
+func (q *StationQuery) Elevation(min, max float64) *StationQuery {
+	return q.Filter(func(a *StationMetadata) bool {
+		return a.Elevation >= min && a.Elevation <= max
+	})
+}
+
+// Near measures the SearchDistance of the stations from the coordinates, the results are
+// sorted nearest first unless sorted otherwise. With a Limit the nearest stations are found with the
+// spatial index of StationInventory
+func (q *StationQuery) Near(lat, lng float64) *StationQuery {
+	q.near, q.lat, q.lng = true, lat, lng
+	return q
+}
+
+// Within matches the stations within km of the coordinates, see Near
+func (q *StationQuery) Within(lat, lng, km float64) *StationQuery {
+	q.radius = km
+	return q.Near(lat, lng)
+}
+
+// BBox matches the stations inside the bounding box, see RawStations.WithinBBox. The results
+// are sorted by name unless sorted otherwise
+func (q *StationQuery) BBox(minLat, minLng, maxLat, maxLng float64) *StationQuery {
+	q.byName = true
+	return q.Filter(func(a *StationMetadata) bool {
+		return a.inBBox(minLat, minLng, maxLat, maxLng)
+	})
+}
+
+// Polygon matches the stations inside the polygons, see RawStations.WithinPolygon. The results
+// are sorted by name unless sorted otherwise
+func (q *StationQuery) Polygon(m MultiPolygon) *StationQuery {
+	q.byName = true
+	minLat, minLng, maxLat, maxLng := m.bounds()
+	return q.Filter(func(a *StationMetadata) bool {
+		return a.inBBox(minLat, minLng, maxLat, maxLng) && m.Contains(a.Latitude, a.Longitude)
+	})
+}
+
+// Interval matches the stations with data for the interval, an unknown interval matches every station
+func (q *StationQuery) Interval(interval Interval) *StationQuery {
+	q.interval = interval
+	return q
+}
+
+// Covering matches the stations with data for every year from start to end, in the range of
+// the Interval of the query or in the range of all the data of the station if it has none
+func (q *StationQuery) Covering(start, end int) *StationQuery {
+	q.start, q.end, q.years = start, end, 0
+	return q
+}
+
+// Overlapping matches the stations with data in at least years of the years from start to end,
+// see Covering
+func (q *StationQuery) Overlapping(start, end, years int) *StationQuery {
+	q.start, q.end, q.years = start, end, years
+	return q
+}
+
+// Sort sorts the results, see RawStations.Sort
+func (q *StationQuery) Sort(by SortBy) *StationQuery {
+	q.sortBy = &by
+	return q
+}
+
+// Limit returns at most n stations, 0 for no limit
+func (q *StationQuery) Limit(n int) *StationQuery {
+	q.limit = n
+	return q
+}
+
+// Offset skips the first n stations of the sorted results
+func (q *StationQuery) Offset(n int) *StationQuery {
+	q.offset = n
+	return q
+}
+
+// match reports whether the station matches every predicate except the distance
+func (q *StationQuery) match(a *StationMetadata) bool {
+	if !a.HasInterval(q.interval) {
+		return false
+	}
+	if (q.start != 0 || q.end != 0) && !a.coverage(q.interval, q.start, q.end, q.years) {
+		return false
+	}
+	for _, f := range q.filters {
+		if !f(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns the stations matching the query, with their SearchDistance if it is Near
+func (r RawStations) Query(q *StationQuery) (s RawStations) {
+	sortBy := SortByName
+	sorted := false
+	if q.sortBy != nil {
+		sortBy, sorted = *q.sortBy, true
+	} else if q.near {
+		sortBy, sorted = SortByDistance, true
+	} else if q.fuzzy != "" {
+		sortBy, sorted = SortByRelevance, true
+	} else if q.byName {
+		sorted = true
+	}
+
+	switch {
+	case q.near && q.radius != 0:
+		s = r.withinRadius(q.lat, q.lng, q.radius, q.match)
+	case q.near && q.limit > 0 && sortBy == SortByDistance:
+		// only the nearest stations of the page are needed
+		s = r.nearest(q.lat, q.lng, q.offset+q.limit, q.match)
+	default:
+		for i := range r {
+			a := r[i]
+			if !q.match(&a) {
+				continue
+			}
+			if q.near {
+				a.Distance(q.lat, q.lng)
+			}
+			s = append(s, a)
+		}
+	}
+
+	if q.fuzzy != "" {
+		for i := range s {
+			s[i].previousScore = FuzzyScore(q.fuzzy, s[i].Name)
+		}
+	}
+
+	if sorted {
+		s.Sort(sortBy)
+	}
+
+	if q.offset > 0 {
+		if q.offset >= len(s) {
+			return nil
+		}
+		s = s[q.offset:]
+	}
+	if q.limit > 0 && len(s) > q.limit {
+		s = s[:q.limit]
+	}
+	return s
+}
@@ -0,0 +1,114 @@
+package weather_gc_ca
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStationQuery(t *testing.T) {
+	lat, lng := 50.4452, -104.6189
+
+	t.Run("combined", func(t *testing.T) {
+		q := NewStationQuery().
+			Interval(Daily).
+			Province("saskatchewan").
+			Within(lat, lng, 300).
+			Covering(1980, 2000).
+			Elevation(0, 1000)
+		s := StationInventory.Query(q)
+
+		count := 0
+		for _, a := range StationInventory {
+			if a.Province == "SASKATCHEWAN" && a.DailyFirstYear != 0 && a.DailyFirstYear <= 1980 && a.DailyLastYear >= 2000 &&
+				a.Elevation <= 1000 && a.Distance(lat, lng) <= 300 {
+				count++
+			}
+		}
+		assert.Equal(t, count, len(s))
+		for i, a := range s {
+			assert.Equal(t, "SASKATCHEWAN", a.Province)
+			if i > 0 {
+				assert.LessOrEqual(t, s[i-1].SearchDistance(), a.SearchDistance(), "sorted by distance")
+			}
+		}
+	})
+
+	t.Run("nearest", func(t *testing.T) {
+		s := StationInventory.Query(NewStationQuery().Near(lat, lng).Interval(Hourly).Limit(10))
+		assert.Len(t, s, 10)
+		assert.Equal(t, StationInventory.FindWithInterval(lat, lng, 10, Hourly)[:10], s)
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		q := func() *StationQuery { return NewStationQuery().Province("ALBERTA").Sort(SortByName) }
+		all := StationInventory.Query(q())
+		if !assert.Greater(t, len(all), 10) {
+			return
+		}
+
+		assert.Equal(t, all[:5], StationInventory.Query(q().Limit(5)))
+		assert.Equal(t, all[5:10], StationInventory.Query(q().Offset(5).Limit(5)))
+		assert.Empty(t, StationInventory.Query(q().Offset(len(all))))
+
+		near := func() *StationQuery { return NewStationQuery().Near(lat, lng) }
+		assert.Equal(t, StationInventory.Query(near().Limit(10))[5:], StationInventory.Query(near().Offset(5).Limit(5)))
+	})
+
+	t.Run("ids", func(t *testing.T) {
+		s := StationInventory.Query(NewStationQuery().IDs(30247, -1))
+		if assert.Len(t, s, 1) {
+			assert.Equal(t, 30247, s[0].StationID)
+		}
+	})
+
+	t.Run("bbox", func(t *testing.T) {
+		within := StationInventory.WithinBBox(50, -105, 51, -104)
+		within.Sort(SortByName)
+		assert.Equal(t, within, StationInventory.Query(NewStationQuery().BBox(50, -105, 51, -104)))
+	})
+
+	t.Run("names", func(t *testing.T) {
+		s := StationInventory.Query(NewStationQuery().NameStartsWith("regina").Interval(Daily))
+		assert.NotEmpty(t, s)
+		for i, a := range s {
+			assert.Contains(t, a.Name, "REGINA")
+			assert.NotZero(t, a.DailyFirstYear)
+			if i > 0 {
+				assert.LessOrEqual(t, s[i-1].Name, a.Name)
+			}
+		}
+		assert.Len(t, StationInventory.NameContains("regina", 3), 3)
+	})
+
+	t.Run("coverage", func(t *testing.T) {
+		covering := StationInventory.Query(NewStationQuery().Interval(Daily).Covering(1981, 2010))
+		overlapping := StationInventory.Query(NewStationQuery().Interval(Daily).Overlapping(1981, 2010, 10))
+		assert.NotEmpty(t, covering)
+		assert.Greater(t, len(overlapping), len(covering))
+		assert.Equal(t, StationInventory.WithCoverage(Daily, 1981, 2010, 0), covering)
+		for _, a := range covering {
+			assert.LessOrEqual(t, a.DailyFirstYear, 1981)
+			assert.GreaterOrEqual(t, a.DailyLastYear, 2010)
+		}
+		for _, a := range overlapping {
+			assert.GreaterOrEqual(t, a.CoverageYears(Daily, 1981, 2010), 10)
+		}
+
+		a := StationMetadata{FirstYear: 1950, LastYear: 2020, DailyFirstYear: 1990, DailyLastYear: 2005}
+		assert.Equal(t, 16, a.CoverageYears(Daily, 1981, 2010))
+		assert.Equal(t, 30, a.CoverageYears(0, 1981, 2010))
+		assert.Equal(t, 0, a.CoverageYears(Daily, 2006, 2010))
+		assert.Equal(t, 0, a.CoverageYears(Hourly, 1981, 2010))
+		assert.True(t, a.Covers(Daily, 1990, 2005))
+		assert.False(t, a.Covers(Daily, 1989, 2005))
+		assert.False(t, a.Covers(Daily, 2005, 1990))
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		s := StationInventory.Query(NewStationQuery().Filter(func(a *StationMetadata) bool { return a.WMOID != "" }).Limit(5))
+		for _, a := range s {
+			assert.NotEmpty(t, a.WMOID)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package weather_gc_ca
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Default completeness of the periods aggregated by a Resampler
+const (
+	DefaultDailyCompleteness   = 0.75 // 18 of the 24 hours of a day
+	DefaultMonthlyCompleteness = 0.9  // 3 missing days of a 31 day month
+)
+
+// Resampler aggregates hourly data into days and daily data into months. An aggregate of
+// a period is valid when the share of the expected records of the period with data for the
+// variable is at least the completeness, a valid aggregate of a period missing records is
+// flagged FlagIncomplete as in the bulk data. An aggregate that is not valid has no data
+type Resampler struct {
+	daily   float64
+	monthly float64
+}
+
+// NewResampler returns a resampler with the default completeness
+func NewResampler() *Resampler {
+	return &Resampler{
+		daily:   DefaultDailyCompleteness,
+		monthly: DefaultMonthlyCompleteness,
+	}
+}
+
+// DailyCompleteness sets the share, from 0 to 1, of the hours of a day needed for a daily value
+func (r *Resampler) DailyCompleteness(share float64) *Resampler {
+	r.daily = share
+	return r
+}
+
+// MonthlyCompleteness sets the share, from 0 to 1, of the days of a month needed for a monthly value
+func (r *Resampler) MonthlyCompleteness(share float64) *Resampler {
+	r.monthly = share
+	return r
+}
+
+// Resample aggregates the data into the interval, hourly data into days or months and daily
+// data into months. Data already in the interval is returned as is
+func (r *Resampler) Resample(data StationDataXML, interval Interval) (StationDataXML, error) {
+	switch d := data.(type) {
+	case *HourlyDataXML:
+		switch interval {
+		case Hourly:
+			return d, nil
+		case Daily:
+			return r.Daily(d), nil
+		case Monthly:
+			return r.Monthly(r.Daily(d)), nil
+		}
+	case *DailyDataXML:
+		switch interval {
+		case Daily:
+			return d, nil
+		case Monthly:
+			return r.Monthly(d), nil
+		}
+	case *MonthlyDataXML:
+		if interval == Monthly {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to resample: cannot resample %T to %s", data, interval)
+}
+
+// Daily aggregates the hourly data into a record for each day of the wall clock of the records,
+// the local standard time of the station unless converted with InUTC. The maximum and minimum
+// temperatures are the extremes of the hourly temperatures, the mean is their average and the
+// degree days are computed from the mean as published by ECCC. The hourly data has no
+// precipitation, snow or gust so these have no data
+func (r *Resampler) Daily(h *HourlyDataXML) *DailyDataXML {
+	days := map[Timeframe][]*HourlyBaseXML{}
+	periods := []Timeframe{}
+	for i := range *h {
+		a := &(*h)[i]
+		t := Timeframe{Year: a.Year, Month: a.Month, Day: a.Day}
+		if _, ok := days[t]; !ok {
+			periods = append(periods, t)
+		}
+		days[t] = append(days[t], a)
+	}
+	sortPeriods(periods)
+
+	d := DailyDataXML{}
+	for _, t := range periods {
+		p := completeness{expected: 24, share: r.daily}
+		temp := aggregate{}
+		for _, a := range days[t] {
+			temp.add(a.Temp)
+		}
+
+		day := DailyBaseXML{
+			Year:    t.Year,
+			Month:   t.Month,
+			Day:     t.Day,
+			MaxTemp: p.measurement(temp, aggregate.max),
+			MinTemp: p.measurement(temp, aggregate.min),
+		}
+		if max, min := day.MaxTemp, day.MinTemp; max.Valid && min.Valid {
+			day.MeanTemp = NewMeasurement(round1((max.Value + min.Value) / 2))
+			day.MeanTemp.Flag = max.Flag
+			day.HeatDegDays = NewMeasurement(round1(math.Max(0, 18-day.MeanTemp.Value)))
+			day.CoolDegDays = NewMeasurement(round1(math.Max(0, day.MeanTemp.Value-18)))
+		}
+		d = append(d, day)
+	}
+	return &d
+}
+
+// Monthly aggregates the daily data into a record for each month. The mean temperatures are
+// the averages of the daily values, the extremes the highest maximum and lowest minimum, the
+// rain, snow and precipitation are totals, the snow on ground is the value of the last day of
+// the month and the gust is the highest gust of the month with its direction
+func (r *Resampler) Monthly(d *DailyDataXML) *MonthlyDataXML {
+	months := map[Timeframe][]*DailyBaseXML{}
+	periods := []Timeframe{}
+	for i := range *d {
+		a := &(*d)[i]
+		t := Timeframe{Year: a.Year, Month: a.Month}
+		if _, ok := months[t]; !ok {
+			periods = append(periods, t)
+		}
+		months[t] = append(months[t], a)
+	}
+	sortPeriods(periods)
+
+	m := MonthlyDataXML{}
+	for _, t := range periods {
+		p := completeness{expected: daysIn(t.Year, t.Month), share: r.monthly}
+		var maxTemp, minTemp, meanTemp, rain, snow, precip, gust aggregate
+		var last, maxGust *DailyBaseXML
+		for _, a := range months[t] {
+			maxTemp.add(a.MaxTemp)
+			minTemp.add(a.MinTemp)
+			meanTemp.add(a.MeanTemp)
+			rain.add(a.TotalRain)
+			snow.add(a.TotalSnow)
+			precip.add(a.TotalPrecipitation)
+			gust.add(a.MaxGustSpeed.Measurement)
+
+			if last == nil || a.Day > last.Day {
+				last = a
+			}
+			if a.MaxGustSpeed.Valid && (maxGust == nil || a.MaxGustSpeed.Value > maxGust.MaxGustSpeed.Value) {
+				maxGust = a
+			}
+		}
+
+		month := MonthlyBaseXML{
+			Year:               t.Year,
+			Month:              t.Month,
+			MeanMaxTemp:        p.measurement(maxTemp, aggregate.mean),
+			MeanMinTemp:        p.measurement(minTemp, aggregate.mean),
+			MeanTemp:           p.measurement(meanTemp, aggregate.mean),
+			ExtremeMaxTemp:     p.measurement(maxTemp, aggregate.max),
+			ExtremeMinTemp:     p.measurement(minTemp, aggregate.min),
+			TotalRain:          p.measurement(rain, aggregate.sum),
+			TotalSnow:          p.measurement(snow, aggregate.sum),
+			TotalPrecipitation: p.measurement(precip, aggregate.sum),
+		}
+		if last.Day == p.expected {
+			month.SnowOnGround = last.SnowOnGround
+		}
+		if g := p.measurement(gust, aggregate.max); g.Valid {
+			month.MaxGustSpeed = BoundedMeasurement{Measurement: g, Comparator: maxGust.MaxGustSpeed.Comparator}
+			month.MaxGustDirection = maxGust.MaxGustDirection
+		}
+		m = append(m, month)
+	}
+	return &m
+}
+
+// completeness is the completeness required of the aggregates of a period
+type completeness struct {
+	expected int // the records of a complete period
+	share    float64
+}
+
+// measurement returns the aggregate of the values, no data if too few of the expected
+// records have a value and flagged FlagIncomplete if any is missing
+func (p completeness) measurement(a aggregate, f func(a aggregate) float64) Measurement {
+	// the epsilon keeps a share that is a whole number of records from rounding up
+	if len(a) == 0 || float64(len(a)) < math.Ceil(p.share*float64(p.expected)-1e-9) {
+		return Measurement{}
+	}
+
+	m := NewMeasurement(f(a))
+	if len(a) < p.expected {
+		m.Flag = lookupFlag(FlagIncomplete)
+	}
+	return m
+}
+
+// aggregate is the values of a variable over a period
+type aggregate []float64
+
+func (a *aggregate) add(m Measurement) {
+	if m.Valid {
+		*a = append(*a, m.Value)
+	}
+}
+
+func (a aggregate) sum() float64 {
+	s := 0.0
+	for _, v := range a {
+		s += v
+	}
+	return round1(s)
+}
+
+func (a aggregate) mean() float64 {
+	s := 0.0
+	for _, v := range a {
+		s += v
+	}
+	return round1(s / float64(len(a)))
+}
+
+func (a aggregate) max() float64 {
+	m := a[0]
+	for _, v := range a[1:] {
+		m = math.Max(m, v)
+	}
+	return m
+}
+
+func (a aggregate) min() float64 {
+	m := a[0]
+	for _, v := range a[1:] {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+// round1 rounds to the tenth published in the bulk data
+func round1(v float64) float64 {
+	v = math.Round(v*10) / 10
+	if v == 0 {
+		// no negative zero
+		return 0
+	}
+	return v
+}
+
+// daysIn returns the number of days of the month
+func daysIn(year, month int) int {
+	return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// sortPeriods sorts the periods in chronological order
+func sortPeriods(periods []Timeframe) {
+	sort.Slice(periods, func(i, j int) bool {
+		a, b := periods[i], periods[j]
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		return a.Day < b.Day
+	})
+}
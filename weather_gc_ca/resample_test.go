@@ -0,0 +1,111 @@
+package weather_gc_ca
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResample(t *testing.T) {
+	t.Run("monthly", func(t *testing.T) {
+		d := &DailyDataXML{}
+		decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+		published := &MonthlyDataXML{}
+		decodeTestData(t, "./_testdata/test-monthly_toronto.xml", published)
+
+		m := NewResampler().Monthly(d)
+		if !assert.Len(t, *m, 12) {
+			return
+		}
+		// the complete months of the daily data match the monthly data published by ECCC
+		for _, a := range (*m)[:11] {
+			found, ok := published.Find(a.Timeframe())
+			if !assert.True(t, ok, a.Timeframe()) {
+				continue
+			}
+			b := found.(MonthlyBaseXML)
+			assert.Equal(t, b.MeanMaxTemp.Value, a.MeanMaxTemp.Value, "%s mean max", a.Timeframe())
+			assert.Equal(t, b.MeanTemp.Value, a.MeanTemp.Value, "%s mean", a.Timeframe())
+			assert.Equal(t, b.ExtremeMaxTemp.Value, a.ExtremeMaxTemp.Value, "%s extreme max", a.Timeframe())
+			assert.Equal(t, b.ExtremeMinTemp.Value, a.ExtremeMinTemp.Value, "%s extreme min", a.Timeframe())
+			assert.Equal(t, b.TotalPrecipitation.Value, a.TotalPrecipitation.Value, "%s precipitation", a.Timeframe())
+		}
+
+		jan := (*m)[0]
+		assert.Equal(t, 4.0, jan.SnowOnGround.Value)
+		assert.Equal(t, 83.0, jan.MaxGustSpeed.Value)
+		assert.Equal(t, 35.0, jan.MaxGustDirection.Value)
+		assert.Nil(t, jan.MeanTemp.Flag)
+
+		// 5 days of december have no temperature
+		dec := (*m)[11]
+		assert.False(t, dec.MeanTemp.Valid)
+		dec = (*NewResampler().MonthlyCompleteness(0.8).Monthly(d))[11]
+		assert.True(t, dec.MeanTemp.Flagged(FlagIncomplete))
+	})
+
+	t.Run("completeness", func(t *testing.T) {
+		d := &DailyDataXML{}
+		decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+		january := append(DailyDataXML{}, (*d)[:31]...)
+
+		// 28 of the 31 days are enough, the month is flagged incomplete
+		partial := append(DailyDataXML{}, january[:28]...)
+		m := NewResampler().Monthly(&partial)
+		if assert.Len(t, *m, 1) {
+			assert.True(t, (*m)[0].MeanTemp.Valid)
+			assert.True(t, (*m)[0].MeanTemp.Flagged(FlagIncomplete))
+			assert.False(t, (*m)[0].SnowOnGround.Valid, "the last day is missing")
+		}
+
+		partial = append(DailyDataXML{}, january[:27]...)
+		m = NewResampler().Monthly(&partial)
+		assert.False(t, (*m)[0].MeanTemp.Valid)
+
+		m = NewResampler().MonthlyCompleteness(0.5).Monthly(&partial)
+		assert.True(t, (*m)[0].MeanTemp.Valid)
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		h := &HourlyDataXML{}
+		decodeTestData(t, "./_testdata/test-hourly_toronto.xml", h)
+		published := &DailyDataXML{}
+		decodeTestData(t, "./_testdata/test-daily_toronto.xml", published)
+
+		d := NewResampler().Daily(h)
+		if !assert.Len(t, *d, 31) {
+			return
+		}
+		for _, a := range *d {
+			found, ok := published.Find(a.Timeframe())
+			if !assert.True(t, ok) {
+				continue
+			}
+			// the published climate day ends at 0600 UTC rather than midnight LST
+			b := found.(DailyBaseXML)
+			assert.InDelta(t, b.MaxTemp.Value, a.MaxTemp.Value, 3, "%s max", a.Timeframe())
+			assert.InDelta(t, b.MinTemp.Value, a.MinTemp.Value, 3, "%s min", a.Timeframe())
+			assert.Equal(t, a.HeatDegDays.Value, round1(18-a.MeanTemp.Value))
+			assert.False(t, a.TotalPrecipitation.Valid)
+		}
+
+		first := (*h)[:17]
+		d = NewResampler().Daily(&first)
+		assert.False(t, (*d)[0].MaxTemp.Valid, "17 of 24 hours")
+		d = NewResampler().DailyCompleteness(0.5).Daily(&first)
+		assert.True(t, (*d)[0].MaxTemp.Flagged(FlagIncomplete))
+	})
+
+	t.Run("resample", func(t *testing.T) {
+		h := &HourlyDataXML{}
+		decodeTestData(t, "./_testdata/test-hourly_toronto.xml", h)
+
+		data, err := NewResampler().Resample(h, Monthly)
+		if assert.NoError(t, err) {
+			assert.IsType(t, &MonthlyDataXML{}, data)
+			assert.Len(t, *data.(*MonthlyDataXML), 1)
+		}
+		_, err = NewResampler().Resample(&MonthlyDataXML{}, Daily)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,98 @@
+package weather_gc_ca
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Time types of the hourly data
+const (
+	TimeTypeLST = "LST" // local standard time of the station, daylight saving time is never applied
+	TimeTypeUTC = "UTC"
+)
+
+// StandardTimeOffset returns the offset from UTC of the local standard time at a station,
+// derived from its province and position. Provinces and territories spanning several time
+// zones are split on the boundary, stations outside Canada use the nautical time zone of
+// the longitude
+func StandardTimeOffset(province string, latitude, longitude float64) time.Duration {
+	const (
+		newfoundland = -3*time.Hour - 30*time.Minute
+		atlantic     = -4 * time.Hour
+		eastern      = -5 * time.Hour
+		central      = -6 * time.Hour
+		mountain     = -7 * time.Hour
+		pacific      = -8 * time.Hour
+	)
+
+	switch p := strings.ToUpper(strings.TrimSpace(province)); {
+	case strings.HasPrefix(p, "NEWFOUNDLAND"):
+		// most of Labrador keeps atlantic time
+		if longitude < -59.5 {
+			return atlantic
+		}
+		return newfoundland
+	case p == "NOVA SCOTIA", p == "NEW BRUNSWICK", p == "PRINCE EDWARD ISLAND":
+		return atlantic
+	case p == "QUEBEC":
+		// the lower north shore and the Magdalen Islands keep atlantic time
+		if longitude > -63 {
+			return atlantic
+		}
+		return eastern
+	case p == "ONTARIO":
+		if longitude < -90 {
+			return central
+		}
+		return eastern
+	case p == "MANITOBA", p == "SASKATCHEWAN":
+		return central
+	case p == "ALBERTA", p == "NORTHWEST TERRITORIES":
+		return mountain
+	case p == "BRITISH COLUMBIA":
+		switch {
+		case latitude > 55.5 && longitude > -123:
+			// the Peace River and the Northern Rockies
+			return mountain
+		case latitude < 51.8 && longitude > -117.2:
+			// the East Kootenay, east of Kootenay Lake
+			return mountain
+		}
+		return pacific
+	case strings.HasPrefix(p, "YUKON"):
+		return pacific
+	case p == "NUNAVUT":
+		switch {
+		case longitude < -102:
+			return mountain
+		case longitude < -85:
+			return central
+		}
+		return eastern
+	}
+
+	return time.Duration(math.Round(longitude/15)) * time.Hour
+}
+
+// StandardTimeZone returns the fixed zone of the local standard time at a station,
+// see StandardTimeOffset
+func StandardTimeZone(province string, latitude, longitude float64) *time.Location {
+	offset := StandardTimeOffset(province, latitude, longitude)
+	return time.FixedZone(zoneName(offset), int(offset.Seconds()))
+}
+
+// Location returns the fixed zone of the local standard time of the station
+func (r StationMetadata) Location() *time.Location {
+	return StandardTimeZone(r.Province, r.Latitude, r.Longitude)
+}
+
+// zoneName returns the name of a fixed zone, eg. LST-05:00
+func zoneName(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("%s%s%02d:%02d", TimeTypeLST, sign, int(offset.Hours()), int(offset.Minutes())%60)
+}
@@ -0,0 +1,103 @@
+package weather_gc_ca
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandardTimeOffset(t *testing.T) {
+	tests := []struct {
+		province  string
+		latitude  float64
+		longitude float64
+		want      time.Duration
+	}{
+		{"NEWFOUNDLAND", 47.62, -52.74, -3*time.Hour - 30*time.Minute},
+		{"NEWFOUNDLAND", 53.32, -60.42, -4 * time.Hour}, // Goose Bay
+		{"NOVA SCOTIA", 44.88, -63.57, -4 * time.Hour},
+		{"QUEBEC", 45.47, -73.75, -5 * time.Hour},
+		{"QUEBEC", 47.43, -61.77, -4 * time.Hour}, // Magdalen Islands
+		{"ONTARIO", 43.68, -79.63, -5 * time.Hour},
+		{"ONTARIO", 49.79, -94.36, -6 * time.Hour}, // Kenora
+		{"SASKATCHEWAN", 50.43, -104.62, -6 * time.Hour},
+		{"ALBERTA", 51.11, -114.02, -7 * time.Hour},
+		{"BRITISH COLUMBIA", 49.19, -123.18, -8 * time.Hour},
+		{"BRITISH COLUMBIA", 49.96, -119.38, -8 * time.Hour}, // Kelowna
+		{"BRITISH COLUMBIA", 49.46, -119.6, -8 * time.Hour},  // Penticton
+		{"BRITISH COLUMBIA", 50.96, -118.18, -8 * time.Hour}, // Revelstoke
+		{"BRITISH COLUMBIA", 49.3, -117.63, -8 * time.Hour},  // Castlegar
+		{"BRITISH COLUMBIA", 49.49, -117.29, -8 * time.Hour}, // Nelson
+		{"BRITISH COLUMBIA", 49.61, -115.78, -7 * time.Hour}, // Cranbrook
+		{"BRITISH COLUMBIA", 51.3, -116.98, -7 * time.Hour},  // Golden
+		{"BRITISH COLUMBIA", 56.24, -120.74, -7 * time.Hour}, // Fort St. John
+		{"BRITISH COLUMBIA", 55.74, -120.18, -7 * time.Hour}, // Dawson Creek
+		{"BRITISH COLUMBIA", 54.82, -127.18, -8 * time.Hour}, // Smithers
+		{"YUKON TERRITORY", 60.71, -135.07, -8 * time.Hour},
+		{"NUNAVUT", 63.75, -68.52, -5 * time.Hour},
+		{"NUNAVUT", 74.72, -94.97, -6 * time.Hour},
+		{"NUNAVUT", 69.11, -105.07, -7 * time.Hour},
+		{"", 21.33, -150, -10 * time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StandardTimeOffset(tt.province, tt.latitude, tt.longitude), "%s %.2f %.2f", tt.province, tt.latitude, tt.longitude)
+	}
+
+	_, offset := time.Date(2000, 1, 1, 0, 0, 0, 0, StandardTimeZone("ONTARIO", 43.68, -79.63)).Zone()
+	assert.Equal(t, -5*60*60, offset)
+	assert.Equal(t, "LST-03:30", StandardTimeZone("NEWFOUNDLAND", 47.62, -52.74).String())
+}
+
+func TestHourlyTimezone(t *testing.T) {
+	h := &HourlyDataXML{}
+	decodeTestData(t, "./_testdata/test-hourly_toronto.xml", h)
+
+	// <stationdata timetype="LST" day="1" hour="0" minute="0" month="1" year="1992">
+	first := (*h)[0]
+	assert.Equal(t, TimeTypeLST, first.TimeType)
+	assert.Equal(t, time.Date(1992, 1, 1, 5, 0, 0, 0, time.UTC), first.UTC())
+	assert.Equal(t, 0, first.Local().Hour())
+
+	_, ok := h.Find(Timeframe{Year: 1992, Month: 1, Day: 1, Time: time.Date(1992, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.True(t, ok)
+
+	t.Run("utc", func(t *testing.T) {
+		u := InUTC(h).(*HourlyDataXML)
+		assert.Equal(t, len(*h), len(*u))
+
+		a := (*u)[0]
+		assert.Equal(t, TimeTypeUTC, a.TimeType)
+		assert.Equal(t, 5, a.Hour)
+		assert.True(t, a.UTC().Equal(first.UTC()))
+
+		rows := u.csv()
+		assert.Equal(t, "TimeType", rows[0][5])
+		assert.Equal(t, []string{"1992", "1", "1", "5", "0", "UTC"}, rows[1][:6])
+
+		// the original data is unchanged
+		assert.Equal(t, 0, (*h)[0].Hour)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		b, err := json.Marshal(first)
+		assert.NoError(t, err)
+
+		fields := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(b, &fields))
+		assert.Equal(t, "1992-01-01T00:00:00-05:00", fields["time"])
+		assert.Equal(t, TimeTypeLST, fields["timeType"])
+
+		decoded := HourlyBaseXML{}
+		assert.NoError(t, json.Unmarshal(b, &decoded))
+		assert.True(t, decoded.UTC().Equal(first.UTC()))
+		assert.Equal(t, first.Hour, decoded.Hour)
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		d := &DailyDataXML{}
+		decodeTestData(t, "./_testdata/test-daily_toronto.xml", d)
+		assert.Equal(t, d, InUTC(d))
+	})
+}
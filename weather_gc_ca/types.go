@@ -3,6 +3,9 @@ package weather_gc_ca
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -10,6 +13,8 @@ type RawStations []StationMetadata
 
 type StationMetadata struct {
 	previousDistance float64
+	previousScore    float64
+	client           *Client
 
 	XML              ClimateDataXML `xml:"-" json:"-"`
 	Name             string         `json:"Name"`
@@ -33,10 +38,21 @@ type StationMetadata struct {
 	MonthlyLastYear  int            `json:"MLY Last Year"`
 }
 
+// MarshalJSON encodes the metadata of the station, distance is the distance in km
+// from the point of the search that found the station, 0 if it was not searched by distance,
+// and score is the relevance of its name to a fuzzy search, 0 if it was not searched by name
 func (s StationMetadata) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]interface{}{
+	return json.Marshal(s.properties())
+}
+
+// properties returns the metadata of the station encoded by MarshalJSON
+func (s StationMetadata) properties() map[string]interface{} {
+	return map[string]interface{}{
 		"name":             s.Name,
 		"stationID":        s.StationID,
+		"climateID":        s.ClimateID,
+		"wmoID":            s.WMOID,
+		"tcID":             s.TCID,
 		"province":         s.Province,
 		"latitude":         s.Latitude,
 		"longitude":        s.Longitude,
@@ -49,7 +65,9 @@ func (s StationMetadata) MarshalJSON() ([]byte, error) {
 		"dailyLastYear":    s.DailyLastYear,
 		"monthlyFirstYear": s.MonthlyFirstYear,
 		"monthlyLastYear":  s.MonthlyLastYear,
-	})
+		"distance":         s.SearchDistance(),
+		"score":            s.previousScore,
+	}
 }
 
 type Interval int
@@ -68,6 +86,21 @@ func (i Interval) String() string {
 	return "Unknown"
 }
 
+// IntervalString parses an interval from its name or number, returning 0 if unknown
+func IntervalString(a string) Interval {
+	switch strings.ToLower(a) {
+	case "hourly", "1":
+		return Hourly
+	case "daily", "2":
+		return Daily
+	case "monthly", "3":
+		return Monthly
+	case "almanac", "4":
+		return Almanac
+	}
+	return 0
+}
+
 const (
 	Hourly  Interval = 1
 	Daily   Interval = 2
@@ -93,6 +126,33 @@ func (t Timeframe) String() string {
 type DownloadStatus struct {
 	Progress chan DownloadProgress
 	Done     chan bool
+	failed   *failedPeriods
+}
+
+// Failed returns the periods that could not be retreived after all retries,
+// the list is complete once Done has been received
+func (d DownloadStatus) Failed() []*RequestError {
+	if d.failed == nil {
+		return nil
+	}
+	d.failed.mu.Lock()
+	defer d.failed.mu.Unlock()
+	return append([]*RequestError{}, d.failed.list...)
+}
+
+type failedPeriods struct {
+	mu   sync.Mutex
+	list []*RequestError
+}
+
+func (f *failedPeriods) add(err error) {
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		reqErr = &RequestError{Err: err}
+	}
+	f.mu.Lock()
+	f.list = append(f.list, reqErr)
+	f.mu.Unlock()
 }
 
 type DownloadProgress struct {
@@ -101,9 +161,40 @@ type DownloadProgress struct {
 	Count     int
 	Time      int64
 	Error     error
+	Data      StationDataXML // the dataset downloaded in this step
 }
 
 var (
 	ErrContextCancelled = errors.New("context cancelled")
 	ErrRequestFailed    = errors.New("request failed")
+	ErrNotFound         = errors.New("dataset not found")
+	ErrThrottled        = errors.New("request throttled")
+	ErrServer           = errors.New("server error")
+	ErrNetwork          = errors.New("network error")
+	ErrMalformedXML     = errors.New("malformed xml")
 )
+
+// RequestError is returned when a dataset could not be retreived after all attempts,
+// Err wraps one of ErrNotFound, ErrThrottled, ErrServer, ErrNetwork or ErrMalformedXML
+// when the cause is known. It also matches ErrRequestFailed with errors.Is
+type RequestError struct {
+	StationID int
+	Year      int
+	Month     int
+	Interval  Interval
+	Attempts  int
+	Err       error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("failed to retreive %s dataset for station %d %d-%02d after %d attempt(s): %s",
+		e.Interval, e.StationID, e.Year, e.Month, e.Attempts, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	return target == ErrRequestFailed
+}
@@ -20,6 +20,7 @@ type StationDataXML interface {
 	Append(StationDataXML)
 	csv() [][]string
 	Empty() bool
+	Exclude(flags ...string) StationDataXML
 	Find(Timeframe) (IntervalBaseXML, bool)
 	First() IntervalBaseXML
 	Last() IntervalBaseXML
@@ -31,30 +32,279 @@ type IntervalBaseXML interface {
 	Timeframe() Timeframe
 }
 
-// TODO: almanac dataset
+// NewStationData returns an empty dataset for the interval, nil if the interval is unknown
+func NewStationData(interval Interval) StationDataXML {
+	switch interval {
+	case Hourly:
+		return &HourlyDataXML{}
+	case Daily:
+		return &DailyDataXML{}
+	case Monthly:
+		return &MonthlyDataXML{}
+	case Almanac:
+		return &AlmanacDataXML{}
+	}
+	return nil
+}
+
+// UnmarshalXML decodes the climatedata document, the almanac dataset is published as
+// <month index><day index> elements rather than <stationdata> and is collected into
+// an *AlmanacDataXML when Data is nil or already an *AlmanacDataXML
+func (c *ClimateDataXML) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	x := struct {
+		Lang        string            `xml:"lang"`
+		StationInfo StationInfoXML    `xml:"stationinformation"`
+		Legend      []FlagsXML        `xml:"legend>flag"`
+		Data        StationDataXML    `xml:"stationdata"`
+		Months      []almanacMonthXML `xml:"month"`
+	}{Data: c.Data}
+
+	err := d.DecodeElement(&x, &start)
+	if err != nil {
+		return err
+	}
+
+	c.XMLName = start.Name
+	c.Lang, c.StationInfo, c.Legend, c.Data = x.Lang, x.StationInfo, x.Legend, x.Data
+	resolveFlags(c.Data, c.Legend)
+	if h, ok := c.Data.(*HourlyDataXML); ok && c.StationInfo.Province != "" {
+		h.SetLocation(StandardTimeZone(c.StationInfo.Province, c.StationInfo.Latitude, c.StationInfo.Longitude))
+	}
+	if len(x.Months) == 0 {
+		return nil
+	}
+
+	a, ok := c.Data.(*AlmanacDataXML)
+	if !ok {
+		if c.Data != nil {
+			return nil
+		}
+		a = &AlmanacDataXML{}
+		c.Data = a
+	}
+
+	for _, m := range x.Months {
+		for _, day := range m.Days {
+			*a = append(*a, day.almanac(m.Index))
+		}
+	}
+
+	return nil
+}
+
+// almanacYear is the leap year used to build the Time of an almanac record,
+// the records describe a day of the year rather than a date
+const almanacYear = 2000
+
+type almanacMonthXML struct {
+	Index int             `xml:"index,attr"`
+	Days  []almanacDayXML `xml:"day"`
+}
+
+type almanacDayXML struct {
+	Index         int               `xml:"index,attr"`
+	Temperature   []AlmanacValueXML `xml:"temperature"`
+	Precipitation []AlmanacValueXML `xml:"precipitation"`
+	POP           float64           `xml:"pop"`
+}
+
+func (d almanacDayXML) almanac(month int) AlmanacBaseXML {
+	a := AlmanacBaseXML{
+		Time:  time.Date(almanacYear, time.Month(month), d.Index, 0, 0, 0, 0, time.UTC),
+		Month: month,
+		Day:   d.Index,
+		POP:   d.POP,
+	}
+
+	for _, v := range append(d.Temperature, d.Precipitation...) {
+		switch v.Class {
+		case "extremeMax":
+			a.ExtremeMaxTemp = v
+		case "extremeMin":
+			a.ExtremeMinTemp = v
+		case "normalMax":
+			a.NormalMaxTemp = v
+		case "normalMin":
+			a.NormalMinTemp = v
+		case "normalMean":
+			a.NormalMeanTemp = v
+		case "extremeRainfall":
+			a.ExtremeRainfall = v
+		case "extremeSnowfall":
+			a.ExtremeSnowfall = v
+		case "extremePrecipitation":
+			a.ExtremePrecipitation = v
+		case "extremeSnowOnGround":
+			a.ExtremeSnowOnGround = v
+		}
+	}
+
+	return a
+}
+
+// AlmanacValueXML is a single almanac measurement, extremes also carry the year
+// the record was set and the period of record, eg. "1939-2013"
+type AlmanacValueXML struct {
+	Class  string  `xml:"class,attr" json:"-"`
+	Year   int     `xml:"year,attr" json:"year,omitempty"`
+	Period string  `xml:"period,attr" json:"period,omitempty"`
+	Value  float64 `xml:",chardata" json:"value"`
+}
+
+func (v AlmanacValueXML) csv() []string {
+	return []string{
+		fmt.Sprintf("%.2f", v.Value),
+		fmt.Sprintf("%d", v.Year),
+		v.Period,
+	}
+}
+
+type AlmanacBaseXML struct {
+	Time                 time.Time       `xml:"-" json:"time"`
+	Month                int             `xml:"-" json:"month"`
+	Day                  int             `xml:"-" json:"day"`
+	ExtremeMaxTemp       AlmanacValueXML `xml:"-" json:"extremeMaxTemp"`
+	ExtremeMinTemp       AlmanacValueXML `xml:"-" json:"extremeMinTemp"`
+	NormalMaxTemp        AlmanacValueXML `xml:"-" json:"normalMaxTemp"`
+	NormalMinTemp        AlmanacValueXML `xml:"-" json:"normalMinTemp"`
+	NormalMeanTemp       AlmanacValueXML `xml:"-" json:"normalMeanTemp"`
+	ExtremeRainfall      AlmanacValueXML `xml:"-" json:"extremeRainfall"`
+	ExtremeSnowfall      AlmanacValueXML `xml:"-" json:"extremeSnowfall"`
+	ExtremePrecipitation AlmanacValueXML `xml:"-" json:"extremePrecip"`
+	ExtremeSnowOnGround  AlmanacValueXML `xml:"-" json:"extremeSnowDepth"`
+	POP                  float64         `xml:"-" json:"pop"` // probability of precipitation (%)
+}
+
+func (a AlmanacBaseXML) Timeframe() Timeframe {
+	return Timeframe{
+		Month: a.Month,
+		Day:   a.Day,
+		Time:  time.Date(almanacYear, time.Month(a.Month), a.Day, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+type AlmanacDataXML []AlmanacBaseXML
+
+func (a *AlmanacDataXML) Append(data StationDataXML) {
+	if v, ok := data.(*AlmanacDataXML); ok {
+		da := (*a)
+		dv := (*v)
+		da = append(da, dv...)
+		*a = da
+	}
+}
+
+func (a *AlmanacDataXML) csv() [][]string {
+	s := [][]string{}
+	header := []string{"Month", "Day"}
+	for _, h := range []string{"ExtremeMaxTemp", "ExtremeMinTemp"} {
+		header = append(header, h, h+"Year", h+"Period")
+	}
+	header = append(header, "NormalMaxTemp", "NormalMinTemp", "NormalMeanTemp")
+	for _, h := range []string{"ExtremeRainfall", "ExtremeSnowfall", "ExtremePrecipitation", "ExtremeSnowOnGround"} {
+		header = append(header, h, h+"Year", h+"Period")
+	}
+	header = append(header, "POP")
+	s = append(s, header)
+
+	for _, d := range *a {
+		row := []string{
+			fmt.Sprintf("%d", d.Month),
+			fmt.Sprintf("%d", d.Day),
+		}
+		row = append(row, d.ExtremeMaxTemp.csv()...)
+		row = append(row, d.ExtremeMinTemp.csv()...)
+		row = append(row,
+			fmt.Sprintf("%.2f", d.NormalMaxTemp.Value),
+			fmt.Sprintf("%.2f", d.NormalMinTemp.Value),
+			fmt.Sprintf("%.2f", d.NormalMeanTemp.Value),
+		)
+		row = append(row, d.ExtremeRainfall.csv()...)
+		row = append(row, d.ExtremeSnowfall.csv()...)
+		row = append(row, d.ExtremePrecipitation.csv()...)
+		row = append(row, d.ExtremeSnowOnGround.csv()...)
+		row = append(row, fmt.Sprintf("%.2f", d.POP))
+		s = append(s, row)
+	}
+
+	return s
+}
+
+func (a *AlmanacDataXML) Empty() bool {
+	return (len(*a) == 0)
+}
+
+// Exclude returns a copy of the data, almanac values are not flagged
+func (a *AlmanacDataXML) Exclude(flags ...string) StationDataXML {
+	da := append(AlmanacDataXML{}, (*a)...)
+	return &da
+}
+
+// Find matches on the month and day only, almanac records are not tied to a year
+func (a *AlmanacDataXML) Find(t Timeframe) (IntervalBaseXML, bool) {
+	for _, d := range *a {
+		if d.Month == t.Month && d.Day == t.Day {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+func (a *AlmanacDataXML) First() IntervalBaseXML {
+	return (*a)[0]
+}
+
+func (a *AlmanacDataXML) Last() IntervalBaseXML {
+	da := (*a)
+	if len(da) == 0 {
+		return nil
+	}
+	return da[len(da)-1]
+}
+
+func (a *AlmanacDataXML) Sort() {
+	da := (*a)
+	sort.Slice(da, func(i, j int) bool {
+		return da[i].Timeframe().Time.Before(da[j].Timeframe().Time)
+	})
+}
+
+func (a *AlmanacDataXML) Timeframe() (start, end Timeframe) {
+	a.Sort()
+	da := (*a)
+	return da[0].Timeframe(), da[len(da)-1].Timeframe()
+}
 
 type MonthlyBaseXML struct {
-	Time               time.Time `xml:"-" json:"time"`
-	Month              int       `xml:"month,attr" json:"month"`
-	Year               int       `xml:"year,attr" json:"year"`
-	MeanMaxTemp        float64   `xml:"meanmaxtemp" json:"maxTemp"`
-	MeanMinTemp        float64   `xml:"meanmintemp" json:"minTemp"`
-	MeanTemp           float64   `xml:"meanmonthtemp" json:"meanTemp"`
-	ExtremeMaxTemp     float64   `xml:"extrmaxtemp" json:"extremeMaxTemp"`
-	ExtremeMinTemp     float64   `xml:"extrmintemp" json:"extremeMinTemp"`
-	TotalRain          float64   `xml:"totrain" json:"rainfall"`
-	TotalSnow          float64   `xml:"totsnow" json:"snowfall"`
-	TotalPrecipitation float64   `xml:"totprecip" json:"totalPrecip"`
-	SnowOnGround       float64   `xml:"grndsnowlastday" json:"snowDepth"`
-	MaxGustDirection   float64   `xml:"dirmaxgust" json:"windDirection"`
-	MaxGustSpeed       string    `xml:"speedmaxgust" json:"windGustSpeed"`
+	Time               time.Time          `xml:"-" json:"time"`
+	Month              int                `xml:"month,attr" json:"month"`
+	Year               int                `xml:"year,attr" json:"year"`
+	MeanMaxTemp        Measurement        `xml:"meanmaxtemp" json:"maxTemp"`
+	MeanMinTemp        Measurement        `xml:"meanmintemp" json:"minTemp"`
+	MeanTemp           Measurement        `xml:"meanmonthtemp" json:"meanTemp"`
+	ExtremeMaxTemp     Measurement        `xml:"extrmaxtemp" json:"extremeMaxTemp"`
+	ExtremeMinTemp     Measurement        `xml:"extrmintemp" json:"extremeMinTemp"`
+	TotalRain          Measurement        `xml:"totrain" json:"rainfall"`
+	TotalSnow          Measurement        `xml:"totsnow" json:"snowfall"`
+	TotalPrecipitation Measurement        `xml:"totprecip" json:"totalPrecip"`
+	SnowOnGround       Measurement        `xml:"grndsnowlastday" json:"snowDepth"`
+	MaxGustDirection   Measurement        `xml:"dirmaxgust" json:"windDirection"`
+	MaxGustSpeed       BoundedMeasurement `xml:"speedmaxgust" json:"windGustSpeed"`
+}
+
+func (m MonthlyBaseXML) MarshalJSON() ([]byte, error) {
+	return marshalRecord(m)
+}
+
+func (m *MonthlyBaseXML) UnmarshalJSON(b []byte) error {
+	return unmarshalRecord(b, m)
 }
 
 func (m MonthlyBaseXML) Timeframe() Timeframe {
 	return Timeframe{
 		Year:  m.Year,
 		Month: m.Month,
-		Time:  time.Date(m.Year, time.Month(m.Month), 1, 0, 0, 0, 0, time.UTC), // TODO: confirm TIMEZONE
+		Time:  time.Date(m.Year, time.Month(m.Month), 1, 0, 0, 0, 0, time.UTC), // a calendar month, not an instant
 	}
 }
 
@@ -74,34 +324,35 @@ func (m *MonthlyDataXML) csv() [][]string {
 	s = append(s, []string{
 		"Year",
 		"Month",
-		"MeanMaxTemp",
-		"MeanMinTemp",
-		"MeanTemp",
-		"ExtremeMaxTemp",
-		"ExtremeMinTemp",
-		"TotalRain",
-		"TotalSnow",
-		"TotalPrecipitation",
-		"SnowOnGround",
-		"MaxGustDirection",
-		"MaxGustSpeed",
+		"MeanMaxTemp", "MeanMaxTempFlag",
+		"MeanMinTemp", "MeanMinTempFlag",
+		"MeanTemp", "MeanTempFlag",
+		"ExtremeMaxTemp", "ExtremeMaxTempFlag",
+		"ExtremeMinTemp", "ExtremeMinTempFlag",
+		"TotalRain", "TotalRainFlag",
+		"TotalSnow", "TotalSnowFlag",
+		"TotalPrecipitation", "TotalPrecipitationFlag",
+		"SnowOnGround", "SnowOnGroundFlag",
+		"MaxGustDirection", "MaxGustDirectionFlag",
+		"MaxGustSpeed", "MaxGustSpeedFlag",
 	})
 	for _, a := range *m {
-		s = append(s, []string{
+		row := []string{
 			fmt.Sprintf("%d", a.Year),
 			fmt.Sprintf("%d", a.Month),
-			fmt.Sprintf("%.2f", a.MeanMaxTemp),
-			fmt.Sprintf("%.2f", a.MeanMinTemp),
-			fmt.Sprintf("%.2f", a.MeanTemp),
-			fmt.Sprintf("%.2f", a.ExtremeMaxTemp),
-			fmt.Sprintf("%.2f", a.ExtremeMinTemp),
-			fmt.Sprintf("%.2f", a.TotalRain),
-			fmt.Sprintf("%.2f", a.TotalSnow),
-			fmt.Sprintf("%.2f", a.TotalPrecipitation),
-			fmt.Sprintf("%.2f", a.SnowOnGround),
-			fmt.Sprintf("%.2f", a.MaxGustDirection),
-			a.MaxGustSpeed,
-		})
+		}
+		row = append(row, a.MeanMaxTemp.csv()...)
+		row = append(row, a.MeanMinTemp.csv()...)
+		row = append(row, a.MeanTemp.csv()...)
+		row = append(row, a.ExtremeMaxTemp.csv()...)
+		row = append(row, a.ExtremeMinTemp.csv()...)
+		row = append(row, a.TotalRain.csv()...)
+		row = append(row, a.TotalSnow.csv()...)
+		row = append(row, a.TotalPrecipitation.csv()...)
+		row = append(row, a.SnowOnGround.csv()...)
+		row = append(row, a.MaxGustDirection.csv()...)
+		row = append(row, a.MaxGustSpeed.csv()...)
+		s = append(s, row)
 	}
 
 	return s
@@ -111,6 +362,14 @@ func (m *MonthlyDataXML) Empty() bool {
 	return (len(*m) == 0)
 }
 
+// Exclude returns a copy of the data with every measurement flagged with one
+// of the flags cleared, eg. Exclude(FlagMissing, FlagEstimated)
+func (m *MonthlyDataXML) Exclude(flags ...string) StationDataXML {
+	dm := append(MonthlyDataXML{}, (*m)...)
+	excludeFlags(&dm, flags)
+	return &dm
+}
+
 func (m *MonthlyDataXML) Find(t Timeframe) (IntervalBaseXML, bool) {
 	dm := (*m)
 	for _, a := range dm {
@@ -127,6 +386,9 @@ func (m *MonthlyDataXML) First() IntervalBaseXML {
 
 func (m *MonthlyDataXML) Last() IntervalBaseXML {
 	dm := (*m)
+	if len(dm) == 0 {
+		return nil
+	}
 	return dm[len(dm)-1]
 }
 func (m *MonthlyDataXML) Sort() {
@@ -143,21 +405,29 @@ func (m *MonthlyDataXML) Timeframe() (start, end Timeframe) {
 }
 
 type DailyBaseXML struct {
-	Time               time.Time `xml:"-" json:"time"`
-	Day                int       `xml:"day,attr" json:"day"`
-	Month              int       `xml:"month,attr" json:"month"`
-	Year               int       `xml:"year,attr" json:"year"`
-	MaxTemp            float64   `xml:"maxtemp" json:"maxTemp"`
-	MinTemp            float64   `xml:"mintemp" json:"minTemp"`
-	MeanTemp           float64   `xml:"meantemp" json:"meanTemp"`
-	HeatDegDays        float64   `xml:"heatdegdays" json:"heatDegDays"`
-	CoolDegDays        float64   `xml:"cooldegdays" json:"coolDegDays"`
-	TotalRain          float64   `xml:"totalrain" json:"rainfall"`
-	TotalSnow          float64   `xml:"totalsnow" json:"snowfall"`
-	TotalPrecipitation float64   `xml:"totalprecipitation" json:"totalPrecip"`
-	SnowOnGround       float64   `xml:"snowonground" json:"snowDepth"`
-	MaxGustDirection   float64   `xml:"dirofmaxgust" json:"windDirection"`
-	MaxGustSpeed       string    `xml:"speedofmaxgust" json:"windGustSpeed"`
+	Time               time.Time          `xml:"-" json:"time"`
+	Day                int                `xml:"day,attr" json:"day"`
+	Month              int                `xml:"month,attr" json:"month"`
+	Year               int                `xml:"year,attr" json:"year"`
+	MaxTemp            Measurement        `xml:"maxtemp" json:"maxTemp"`
+	MinTemp            Measurement        `xml:"mintemp" json:"minTemp"`
+	MeanTemp           Measurement        `xml:"meantemp" json:"meanTemp"`
+	HeatDegDays        Measurement        `xml:"heatdegdays" json:"heatDegDays"`
+	CoolDegDays        Measurement        `xml:"cooldegdays" json:"coolDegDays"`
+	TotalRain          Measurement        `xml:"totalrain" json:"rainfall"`
+	TotalSnow          Measurement        `xml:"totalsnow" json:"snowfall"`
+	TotalPrecipitation Measurement        `xml:"totalprecipitation" json:"totalPrecip"`
+	SnowOnGround       Measurement        `xml:"snowonground" json:"snowDepth"`
+	MaxGustDirection   Measurement        `xml:"dirofmaxgust" json:"windDirection"`
+	MaxGustSpeed       BoundedMeasurement `xml:"speedofmaxgust" json:"windGustSpeed"`
+}
+
+func (d DailyBaseXML) MarshalJSON() ([]byte, error) {
+	return marshalRecord(d)
+}
+
+func (d *DailyBaseXML) UnmarshalJSON(b []byte) error {
+	return unmarshalRecord(b, d)
 }
 
 func (d DailyBaseXML) Timeframe() Timeframe {
@@ -165,7 +435,7 @@ func (d DailyBaseXML) Timeframe() Timeframe {
 		Year:  d.Year,
 		Month: d.Month,
 		Day:   d.Day,
-		Time:  time.Date(d.Year, time.Month(d.Month), d.Day, 0, 0, 0, 0, time.UTC), // TODO: confirm TIMEZONE
+		Time:  time.Date(d.Year, time.Month(d.Month), d.Day, 0, 0, 0, 0, time.UTC), // a climate day in LST, not an instant
 	}
 }
 
@@ -186,35 +456,36 @@ func (d *DailyDataXML) csv() [][]string {
 		"Year",
 		"Month",
 		"Day",
-		"MaxTemp",
-		"MinTemp",
-		"MeanTemp",
-		"HeatDegDays",
-		"CoolDegDays",
-		"TotalRain",
-		"TotalSnow",
-		"TotalPrecipitation",
-		"SnowOnGround",
-		"MaxGustDirection",
-		"MaxGustSpeed",
+		"MaxTemp", "MaxTempFlag",
+		"MinTemp", "MinTempFlag",
+		"MeanTemp", "MeanTempFlag",
+		"HeatDegDays", "HeatDegDaysFlag",
+		"CoolDegDays", "CoolDegDaysFlag",
+		"TotalRain", "TotalRainFlag",
+		"TotalSnow", "TotalSnowFlag",
+		"TotalPrecipitation", "TotalPrecipitationFlag",
+		"SnowOnGround", "SnowOnGroundFlag",
+		"MaxGustDirection", "MaxGustDirectionFlag",
+		"MaxGustSpeed", "MaxGustSpeedFlag",
 	})
 	for _, a := range *d {
-		s = append(s, []string{
+		row := []string{
 			fmt.Sprintf("%d", a.Year),
 			fmt.Sprintf("%d", a.Month),
 			fmt.Sprintf("%d", a.Day),
-			fmt.Sprintf("%.2f", a.MaxTemp),
-			fmt.Sprintf("%.2f", a.MinTemp),
-			fmt.Sprintf("%.2f", a.MeanTemp),
-			fmt.Sprintf("%.2f", a.HeatDegDays),
-			fmt.Sprintf("%.2f", a.CoolDegDays),
-			fmt.Sprintf("%.2f", a.TotalRain),
-			fmt.Sprintf("%.2f", a.TotalSnow),
-			fmt.Sprintf("%.2f", a.TotalPrecipitation),
-			fmt.Sprintf("%.2f", a.SnowOnGround),
-			fmt.Sprintf("%.2f", a.MaxGustDirection),
-			a.MaxGustSpeed,
-		})
+		}
+		row = append(row, a.MaxTemp.csv()...)
+		row = append(row, a.MinTemp.csv()...)
+		row = append(row, a.MeanTemp.csv()...)
+		row = append(row, a.HeatDegDays.csv()...)
+		row = append(row, a.CoolDegDays.csv()...)
+		row = append(row, a.TotalRain.csv()...)
+		row = append(row, a.TotalSnow.csv()...)
+		row = append(row, a.TotalPrecipitation.csv()...)
+		row = append(row, a.SnowOnGround.csv()...)
+		row = append(row, a.MaxGustDirection.csv()...)
+		row = append(row, a.MaxGustSpeed.csv()...)
+		s = append(s, row)
 	}
 	return s
 }
@@ -223,6 +494,14 @@ func (d *DailyDataXML) Empty() bool {
 	return (len(*d) == 0)
 }
 
+// Exclude returns a copy of the data with every measurement flagged with one
+// of the flags cleared, eg. Exclude(FlagMissing, FlagEstimated)
+func (d *DailyDataXML) Exclude(flags ...string) StationDataXML {
+	dd := append(DailyDataXML{}, (*d)...)
+	excludeFlags(&dd, flags)
+	return &dd
+}
+
 func (d *DailyDataXML) Find(t Timeframe) (IntervalBaseXML, bool) {
 	dd := (*d)
 	for _, a := range dd {
@@ -257,34 +536,74 @@ func (d *DailyDataXML) Timeframe() (start, end Timeframe) {
 	return dd[0].Timeframe(), dd[len(dd)-1].Timeframe()
 }
 
+// HourlyBaseXML is an hourly observation. Year to Minute are the wall clock of the
+// TimeType, LST for the bulk data, and Time is the instant of the observation in the
+// zone of the TimeType once the station is known, see HourlyDataXML.SetLocation
 type HourlyBaseXML struct {
-	Time             time.Time `xml:"-" json:"time"`
-	Minute           int       `xml:"minute,attr" json:"minute"`
-	Hour             int       `xml:"hour,attr" json:"hour"`
-	Day              int       `xml:"day,attr" json:"day"`
-	Month            int       `xml:"month,attr" json:"month"`
-	Year             int       `xml:"year,attr" json:"year"`
-	Temp             float64   `xml:"temp" json:"temp"`
-	DewPointTemp     float64   `xml:"dptemp" json:"dewPointTemp"`
-	RelativeHumidity float64   `xml:"relhum" json:"relativeHumidity"`
-	WindDirection    float64   `xml:"winddir" json:"windDirection"`
-	WindSpeed        string    `xml:"windspd" json:"windSpeed"`
-	Visibility       float64   `xml:"visibility" json:"visibility"`
-	StationPressure  float64   `xml:"stnpress" json:"stationPressure"`
-	Humidex          float64   `xml:"humidex" json:"humidex"`
-	Windchill        float64   `xml:"windchill" json:"windchill"`
-	Weather          string    `xml:"weather" json:"weather"`
-}
-
+	Time             time.Time          `xml:"-" json:"time"`
+	TimeType         string             `xml:"timetype,attr" json:"timeType"`
+	Minute           int                `xml:"minute,attr" json:"minute"`
+	Hour             int                `xml:"hour,attr" json:"hour"`
+	Day              int                `xml:"day,attr" json:"day"`
+	Month            int                `xml:"month,attr" json:"month"`
+	Year             int                `xml:"year,attr" json:"year"`
+	Temp             Measurement        `xml:"temp" json:"temp"`
+	DewPointTemp     Measurement        `xml:"dptemp" json:"dewPointTemp"`
+	RelativeHumidity Measurement        `xml:"relhum" json:"relativeHumidity"`
+	WindDirection    Measurement        `xml:"winddir" json:"windDirection"`
+	WindSpeed        BoundedMeasurement `xml:"windspd" json:"windSpeed"`
+	Visibility       Measurement        `xml:"visibility" json:"visibility"`
+	StationPressure  Measurement        `xml:"stnpress" json:"stationPressure"`
+	Humidex          Measurement        `xml:"humidex" json:"humidex"`
+	Windchill        Measurement        `xml:"windchill" json:"windchill"`
+	Weather          string             `xml:"weather" json:"weather"`
+}
+
+func (h HourlyBaseXML) MarshalJSON() ([]byte, error) {
+	return marshalRecord(h)
+}
+
+func (h *HourlyBaseXML) UnmarshalJSON(b []byte) error {
+	return unmarshalRecord(b, h)
+}
+
+// Timeframe returns the wall clock of the record in the zone of Time,
+// in UTC if the zone is not known
 func (h HourlyBaseXML) Timeframe() Timeframe {
 	return Timeframe{
 		Year:  h.Year,
 		Month: h.Month,
 		Day:   h.Day,
-		Time:  time.Date(h.Year, time.Month(h.Month), h.Day, h.Hour, h.Minute, 0, 0, time.UTC), // TODO: confirm TIMEZONE
+		Time:  time.Date(h.Year, time.Month(h.Month), h.Day, h.Hour, h.Minute, 0, 0, h.location()),
 	}
 }
 
+// Local returns the time of the observation in the local standard time of the station
+func (h HourlyBaseXML) Local() time.Time {
+	return h.Timeframe().Time
+}
+
+// UTC returns the true UTC time of the observation
+func (h HourlyBaseXML) UTC() time.Time {
+	return h.Timeframe().Time.UTC()
+}
+
+func (h HourlyBaseXML) location() *time.Location {
+	if h.Time.IsZero() {
+		return time.UTC
+	}
+	return h.Time.Location()
+}
+
+// in returns the record with the wall clock and the Time in the zone
+func (h HourlyBaseXML) in(loc *time.Location, timeType string) HourlyBaseXML {
+	h.Time = h.Timeframe().Time.In(loc)
+	h.Year, h.Day, h.Hour, h.Minute = h.Time.Year(), h.Time.Day(), h.Time.Hour(), h.Time.Minute()
+	h.Month = int(h.Time.Month())
+	h.TimeType = timeType
+	return h
+}
+
 type HourlyDataXML []HourlyBaseXML
 
 func (h *HourlyDataXML) Append(data StationDataXML) {
@@ -304,36 +623,39 @@ func (h *HourlyDataXML) csv() [][]string {
 		"Day",
 		"Hour",
 		"Minute",
-		"Temp",
-		"DewPointTemp",
-		"RelativeHumidity",
-		"WindDirection",
-		"WindSpeed",
-		"Visibility",
-		"StationPressure",
-		"Humidex",
-		"Windchill",
+		"TimeType",
+		"Temp", "TempFlag",
+		"DewPointTemp", "DewPointTempFlag",
+		"RelativeHumidity", "RelativeHumidityFlag",
+		"WindDirection", "WindDirectionFlag",
+		"WindSpeed", "WindSpeedFlag",
+		"Visibility", "VisibilityFlag",
+		"StationPressure", "StationPressureFlag",
+		"Humidex", "HumidexFlag",
+		"Windchill", "WindchillFlag",
 		"Weather",
 	})
 
 	for _, a := range *h {
-		s = append(s, []string{
+		row := []string{
 			fmt.Sprintf("%d", a.Year),
 			fmt.Sprintf("%d", a.Month),
 			fmt.Sprintf("%d", a.Day),
 			fmt.Sprintf("%d", a.Hour),
 			fmt.Sprintf("%d", a.Minute),
-			fmt.Sprintf("%.2f", a.Temp),
-			fmt.Sprintf("%.2f", a.DewPointTemp),
-			fmt.Sprintf("%.2f", a.RelativeHumidity),
-			fmt.Sprintf("%.2f", a.WindDirection),
-			a.WindSpeed,
-			fmt.Sprintf("%.2f", a.Visibility),
-			fmt.Sprintf("%.2f", a.StationPressure),
-			fmt.Sprintf("%.2f", a.Humidex),
-			fmt.Sprintf("%.2f", a.Windchill),
-			a.Weather,
-		})
+			a.TimeType,
+		}
+		row = append(row, a.Temp.csv()...)
+		row = append(row, a.DewPointTemp.csv()...)
+		row = append(row, a.RelativeHumidity.csv()...)
+		row = append(row, a.WindDirection.csv()...)
+		row = append(row, a.WindSpeed.csv()...)
+		row = append(row, a.Visibility.csv()...)
+		row = append(row, a.StationPressure.csv()...)
+		row = append(row, a.Humidex.csv()...)
+		row = append(row, a.Windchill.csv()...)
+		row = append(row, a.Weather)
+		s = append(s, row)
 	}
 
 	return s
@@ -343,22 +665,66 @@ func (h *HourlyDataXML) Empty() bool {
 	return (len(*h) == 0)
 }
 
+// Exclude returns a copy of the data with every measurement flagged with one
+// of the flags cleared, eg. Exclude(FlagMissing, FlagEstimated)
+func (h *HourlyDataXML) Exclude(flags ...string) StationDataXML {
+	hd := append(HourlyDataXML{}, (*h)...)
+	excludeFlags(&hd, flags)
+	return &hd
+}
+
+// Find returns the record at the wall clock of t.Time, whatever the zone of the record
 func (h *HourlyDataXML) Find(t Timeframe) (IntervalBaseXML, bool) {
 	hd := (*h)
 	for _, a := range hd {
-		if a.Timeframe().Time.Equal(t.Time) {
+		if a.Year == t.Time.Year() && a.Month == int(t.Time.Month()) && a.Day == t.Time.Day() &&
+			a.Hour == t.Time.Hour() && a.Minute == t.Time.Minute() {
 			return a, true
 		}
 	}
 	return nil, false
 }
 
+// SetLocation sets the zone of the records in local standard time, eg. to the
+// StandardTimeZone of the station. The wall clock of the records is unchanged
+func (h *HourlyDataXML) SetLocation(loc *time.Location) {
+	hd := (*h)
+	for i := range hd {
+		if hd[i].TimeType != TimeTypeLST {
+			continue
+		}
+		hd[i].Time = time.Date(hd[i].Year, time.Month(hd[i].Month), hd[i].Day, hd[i].Hour, hd[i].Minute, 0, 0, loc)
+	}
+}
+
+// InUTC returns the hourly data in UTC, see HourlyDataXML.UTC, the other intervals
+// are calendar dates and are returned unchanged
+func InUTC(data StationDataXML) StationDataXML {
+	if h, ok := data.(*HourlyDataXML); ok {
+		return h.UTC()
+	}
+	return data
+}
+
+// UTC returns a copy of the data with the records in UTC, the CSV and JSON of the
+// copy hold the true UTC time of each observation
+func (h *HourlyDataXML) UTC() *HourlyDataXML {
+	hd := make(HourlyDataXML, len(*h))
+	for i, a := range *h {
+		hd[i] = a.in(time.UTC, TimeTypeUTC)
+	}
+	return &hd
+}
+
 func (h *HourlyDataXML) First() IntervalBaseXML {
 	return (*h)[0]
 }
 
 func (h *HourlyDataXML) Last() IntervalBaseXML {
 	hd := (*h)
+	if len(hd) == 0 {
+		return nil
+	}
 	return hd[len(hd)-1]
 }
 